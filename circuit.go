@@ -0,0 +1,259 @@
+package miningmonitor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitGroup is a set of clients sharing the same electrical circuit. It is used to coordinate
+// power cycling so that many clients do not power on at the same time and trip the breaker, and to
+// keep the sum of the clients power within the budget of the circuit.
+type CircuitGroup struct {
+	Name string
+	// MinPowerOnGap is the minimum time between two clients of the group powering on
+	MinPowerOnGap time.Duration
+	// MaxPowerOns is the maximum number of clients allowed to power cycle at the same time, <= 0 for no limit
+	MaxPowerOns int
+
+	// Budget in watts for the sum of the clients power, <= 0 for no budget
+	Budget float64
+	// BudgetDuration the load has to stay over budget before alerting or shedding load
+	BudgetDuration time.Duration
+	// EnforceBudget refuses to power on a client if its power would take the group over budget
+	EnforceBudget bool
+	// ShedLoad powers off the lowest priority client while the group is over budget
+	ShedLoad bool
+
+	mu          sync.Mutex
+	powerOns    int
+	lastPowerOn time.Time
+	loads       map[Client]*clientLoad
+	overSince   time.Time
+	alerted     bool
+}
+
+// clientLoad is the last known power of a client within a circuit group
+type clientLoad struct {
+	power    float64
+	peak     float64
+	priority int
+	shed     bool
+	// shutdown is set while the client is powered off by a threshold, it is never shed nor restored by the group
+	shutdown bool
+}
+
+// NewCircuitGroup returns a new circuit group which allows at most maxPowerOns concurrent power cycles
+// with a gap of at least minPowerOnGap between each client powering on.
+func NewCircuitGroup(name string, minPowerOnGap time.Duration, maxPowerOns int) *CircuitGroup {
+	return &CircuitGroup{
+		Name:          name,
+		MinPowerOnGap: minPowerOnGap,
+		MaxPowerOns:   maxPowerOns,
+		loads:         map[Client]*clientLoad{},
+	}
+}
+
+// SetBudget of the circuit group in watts. The load has to stay over budget for the given duration before
+// an alert is sent, if enforce is set clients will not be powered on when they would exceed the budget and
+// if shedLoad is set the lowest priority client will be powered off while the group is over budget.
+func (g *CircuitGroup) SetBudget(budget float64, duration time.Duration, enforce, shedLoad bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.Budget = budget
+	g.BudgetDuration = duration
+	g.EnforceBudget = enforce
+	g.ShedLoad = shedLoad
+}
+
+// Load returns the sum of the last known power of all clients in the group
+func (g *CircuitGroup) Load() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.load(nil)
+}
+
+// load returns the sum of the power of all clients except the one given, g.mu must be held
+func (g *CircuitGroup) load(except Client) float64 {
+	load := 0.0
+	for c, l := range g.loads {
+		if c != except {
+			load += l.power
+		}
+	}
+	return load
+}
+
+// recordPower of a client and returns the load of the group, whether it has been over budget for longer
+// than BudgetDuration and if an alert should be sent for it
+func (g *CircuitGroup) recordPower(c Client, priority int, power float64) (load float64, over, alert bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	l, ok := g.loads[c]
+	if !ok {
+		l = &clientLoad{}
+		g.loads[c] = l
+	}
+	l.power = power
+	l.priority = priority
+	if power > l.peak {
+		l.peak = power
+	}
+
+	load = g.load(nil)
+	if g.Budget <= 0 || load <= g.Budget {
+		g.overSince = time.Time{}
+		g.alerted = false
+		return load, false, false
+	}
+	if g.overSince.IsZero() {
+		g.overSince = time.Now()
+	}
+	if time.Since(g.overSince) < g.BudgetDuration {
+		return load, false, false
+	}
+	alert = !g.alerted
+	g.alerted = true
+	return load, true, alert
+}
+
+// shedCandidate returns the lowest priority client which is still drawing power, the last powered on client
+// of the group is never shed.
+func (g *CircuitGroup) shedCandidate() Client {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var candidate Client
+	powered := 0
+	for c, l := range g.loads {
+		if l.shed || l.shutdown || l.power <= 0 {
+			continue
+		}
+		powered++
+		if candidate == nil || l.priority < g.loads[candidate].priority {
+			candidate = c
+		}
+	}
+	if powered < 2 {
+		return nil
+	}
+	return candidate
+}
+
+// markShed records the client as powered off to bring the group within budget
+func (g *CircuitGroup) markShed(c Client) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	l, ok := g.loads[c]
+	if !ok {
+		return
+	}
+	l.shed = true
+	l.power = 0
+	// Give the group time to settle before shedding another client
+	g.overSince = time.Now()
+}
+
+// setShutdown records whether the client is powered off by a threshold, a shut down client draws no power
+func (g *CircuitGroup) setShutdown(c Client, shutdown bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	l, ok := g.loads[c]
+	if !ok {
+		return
+	}
+	l.shutdown = shutdown
+	if shutdown {
+		l.power = 0
+	}
+}
+
+// shed returns true if the client was powered off to bring the group within budget
+func (g *CircuitGroup) shed(c Client) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	l, ok := g.loads[c]
+	return ok && l.shed
+}
+
+// restore a shed client if its peak power fits within the budget, returns true if it can be powered on
+func (g *CircuitGroup) restore(c Client) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	l, ok := g.loads[c]
+	if !ok || !l.shed || l.shutdown || g.load(c)+l.peak > g.Budget {
+		return false
+	}
+	l.shed = false
+	return true
+}
+
+// checkPowerOn returns an error if the budget is enforced and powering on the client would exceed it
+func (g *CircuitGroup) checkPowerOn(c Client) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.EnforceBudget || g.Budget <= 0 {
+		return nil
+	}
+	l, ok := g.loads[c]
+	if !ok {
+		return nil
+	}
+	if load := g.load(c) + l.peak; load > g.Budget {
+		return fmt.Errorf("powering on would take circuit group %s to %0.2fW over its budget of %0.2fW", g.Name, load, g.Budget)
+	}
+	return nil
+}
+
+// acquirePowerOn blocks until a client is allowed to power on within the group or the context is done
+func (g *CircuitGroup) acquirePowerOn(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for {
+		wait := g.MinPowerOnGap - time.Since(g.lastPowerOn)
+		if wait <= 0 && (g.MaxPowerOns <= 0 || g.powerOns < g.MaxPowerOns) {
+			break
+		}
+		if wait < time.Second {
+			wait = time.Second
+		}
+		g.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			g.mu.Lock()
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		g.mu.Lock()
+	}
+	g.powerOns++
+	g.lastPowerOn = time.Now()
+	return nil
+}
+
+// runPowerOn runs op powering on a client after acquirePowerOn and releases the power on once op returns. Power
+// services cannot be cancelled so op is given a context which is never done, runPowerOn returns once the context
+// is done but the power on is only released when op returns, so that no other client powers on while this client
+// may still be switching on.
+func (g *CircuitGroup) runPowerOn(ctx context.Context, op func(ctx context.Context) error) error {
+	done := make(chan error, 1)
+	go func() {
+		err := op(context.Background())
+		g.releasePowerOn()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// releasePowerOn marks a client as powered on, the gap to the next power on starts from now
+func (g *CircuitGroup) releasePowerOn() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.powerOns--
+	g.lastPowerOn = time.Now()
+}
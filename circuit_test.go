@@ -0,0 +1,129 @@
+package miningmonitor
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCircuitGroupAcquirePowerOn(t *testing.T) {
+	g := NewCircuitGroup("test", 0, 1)
+	if err := g.acquirePowerOn(context.Background()); err != nil {
+		t.Fatalf("acquirePowerOn returned error: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := g.acquirePowerOn(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("acquirePowerOn over MaxPowerOns = %v, want %v", err, context.DeadlineExceeded)
+	}
+	g.releasePowerOn()
+	if err := g.acquirePowerOn(context.Background()); err != nil {
+		t.Errorf("acquirePowerOn after release returned error: %s", err)
+	}
+}
+
+func TestCircuitGroupMinPowerOnGap(t *testing.T) {
+	g := NewCircuitGroup("test", time.Hour, 0)
+	if err := g.acquirePowerOn(context.Background()); err != nil {
+		t.Fatalf("acquirePowerOn returned error: %s", err)
+	}
+	g.releasePowerOn()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := g.acquirePowerOn(ctx); err != context.DeadlineExceeded {
+		t.Errorf("acquirePowerOn within MinPowerOnGap = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCircuitGroupRunPowerOnHoldsUntilReturned(t *testing.T) {
+	g := NewCircuitGroup("test", 0, 1)
+	if err := g.acquirePowerOn(context.Background()); err != nil {
+		t.Fatalf("acquirePowerOn returned error: %s", err)
+	}
+	unblock := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := g.runPowerOn(ctx, func(ctx context.Context) error {
+		<-unblock
+		return nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("runPowerOn = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The power on is held while the power service has not returned
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer waitCancel()
+	if err := g.acquirePowerOn(waitCtx); err != context.DeadlineExceeded {
+		t.Fatalf("acquirePowerOn while powering on = %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(unblock)
+	waitCtx, waitCancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer waitCancel()
+	if err := g.acquirePowerOn(waitCtx); err != nil {
+		t.Errorf("acquirePowerOn after power on returned error: %s", err)
+	}
+}
+
+func TestCircuitGroupShedCandidate(t *testing.T) {
+	low, high, off, down := &testClient{ip: "low"}, &testClient{ip: "high"}, &testClient{ip: "off"}, &testClient{ip: "down"}
+	g := NewCircuitGroup("test", 0, 0)
+	g.recordPower(high, 10, 500)
+	if c := g.shedCandidate(); c != nil {
+		t.Errorf("shedCandidate with one powered client = %s, want none", c.IP())
+	}
+	g.recordPower(low, 1, 400)
+	g.recordPower(off, 0, 0)
+	g.recordPower(down, 0, 300)
+	g.setShutdown(down, true)
+	if c := g.shedCandidate(); c != low {
+		t.Errorf("shedCandidate = %v, want low", c)
+	}
+	g.markShed(low)
+	if c := g.shedCandidate(); c != nil {
+		t.Errorf("shedCandidate with one powered client left = %s, want none", c.IP())
+	}
+}
+
+func TestCircuitGroupRestore(t *testing.T) {
+	a, b := &testClient{ip: "a"}, &testClient{ip: "b"}
+	g := NewCircuitGroup("test", 0, 0)
+	g.SetBudget(1000, 0, true, true)
+	g.recordPower(a, 0, 600)
+	g.recordPower(b, 1, 500)
+	g.markShed(a)
+	if g.restore(a) {
+		t.Error("restored a client over budget")
+	}
+	if err := g.checkPowerOn(a); err == nil {
+		t.Error("checkPowerOn allowed a client over budget")
+	}
+	g.recordPower(b, 1, 300)
+	if !g.restore(a) {
+		t.Error("did not restore a client within budget")
+	}
+	if g.shed(a) {
+		t.Error("client still shed after restore")
+	}
+
+	g.setShutdown(a, true)
+	g.markShed(a)
+	if g.restore(a) {
+		t.Error("restored a shut down client")
+	}
+}
+
+func TestCircuitGroupRecordPowerBudget(t *testing.T) {
+	a, b := &testClient{ip: "a"}, &testClient{ip: "b"}
+	g := NewCircuitGroup("test", 0, 0)
+	g.SetBudget(1000, 0, false, false)
+	if load, over, alert := g.recordPower(a, 0, 600); load != 600 || over || alert {
+		t.Errorf("recordPower = %0.2f %t %t, want 600 within budget", load, over, alert)
+	}
+	if load, over, alert := g.recordPower(b, 0, 600); load != 1200 || !over || !alert {
+		t.Errorf("recordPower = %0.2f %t %t, want 1200 over budget with alert", load, over, alert)
+	}
+	if _, over, alert := g.recordPower(b, 0, 600); !over || alert {
+		t.Errorf("recordPower = %t %t, want over budget without a second alert", over, alert)
+	}
+}
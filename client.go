@@ -1,20 +1,25 @@
 package miningmonitor
 
-// Client interface to the mining software
+import (
+	"context"
+	"fmt"
+)
+
+// Client interface to the mining software. Requests to the client are bounded by the given context.
 type Client interface {
 	// IP of the client
 	IP() string
 	// Stats returns information about the current state used to check thresholds
-	Stats() (*Statistics, error)
+	Stats(ctx context.Context) (*Statistics, error)
 	// Reboot the client if it is available and enabled
-	Reboot() error
+	Reboot(ctx context.Context) error
 	// Restart the client if it is available and enabled
-	Restart() error
+	Restart(ctx context.Context) error
 
 	// PowerCycleEnabled bool to indicate if this client can be power cycled
 	PowerCycleEnabled() bool
 	// PowerCycle the client using an external API enabled power plug.
-	PowerCycle() error
+	PowerCycle(ctx context.Context) error
 
 	// SetReadOnly to disable changing the state of the client
 	SetReadOnly(readOnly, failOnWrites bool)
@@ -22,6 +27,57 @@ type Client interface {
 	ReadOnly() bool
 }
 
+// ConnectionError is returned by a client which failed to connect to, write to or read a reply from the remote
+// host. Only connection errors count towards a client being unreachable, a client returning a reply it cannot
+// parse is still reachable.
+type ConnectionError struct {
+	Addr string
+	// Op that failed, e.g. "connect to"
+	Op  string
+	Err error
+}
+
+// Error returns a human readable format of the connection error
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("failed to %s remote addr %s: %s", e.Op, e.Addr, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// PowerMeter is implemented by clients that can read their power usage from their power service
+type PowerMeter interface {
+	// PowerState returns the power state of the client, read separately from Stats so that a failing power
+	// service does not make the client look unreachable
+	PowerState(ctx context.Context) (*PowerState, error)
+}
+
+// PowerController is implemented by clients that can be turned off and on using their power service
+type PowerController interface {
+	// PowerOff the client using an external API enabled power plug.
+	PowerOff(ctx context.Context) error
+	// PowerOn the client using an external API enabled power plug.
+	PowerOn(ctx context.Context) error
+}
+
+// GPUController is implemented by clients that can enable and disable individual GPUs
+type GPUController interface {
+	// SetGPUEnabled enables or disables the GPU at the given index, -1 for all GPUs
+	SetGPUEnabled(ctx context.Context, gpu int, enabled bool) error
+	// SetGPUMainOnly stops dual mining on the GPU at the given index while it keeps mining the main algorithm
+	SetGPUMainOnly(ctx context.Context, gpu int) error
+}
+
+// ConfigFileManager is implemented by clients that can read and write the configuration files of the mining software
+type ConfigFileManager interface {
+	// ReadConfigFile returns the contents of the named configuration file
+	ReadConfigFile(ctx context.Context, name string) ([]byte, error)
+	// WriteConfigFile replaces the contents of the named configuration file if the client is not read only
+	WriteConfigFile(ctx context.Context, name string, data []byte) error
+}
+
 // Statistics of a client, used for determining thresholds.
 type Statistics struct {
 	Version         string
@@ -53,3 +109,19 @@ type Statistics struct {
 
 	PowerState *PowerState
 }
+
+// alt returns a copy of the statistics with the Alt statistics in place of the Main statistics
+func (s *Statistics) alt() *Statistics {
+	alt := *s
+	alt.MainMiningPool = s.AltMiningPool
+	alt.MainHashRate = s.AltHashRate
+	alt.MainShares = s.AltShares
+	alt.MainRejectedShares = s.AltRejectedShares
+	alt.MainGpuHashRate = s.AltGpuHashRate
+	alt.MainGpuShares = s.AltGpuShares
+	alt.MainGpuRejectedShares = s.AltGpuRejectedShares
+	alt.MainGpuInvalidShares = s.AltGpuInvalidShares
+	alt.MainPoolSwitches = s.AltPoolSwitches
+	alt.MainInvalidShares = s.AltInvalidShares
+	return &alt
+}
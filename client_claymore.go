@@ -1,11 +1,16 @@
 package miningmonitor
 
 import (
+	"context"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang/glog"
 )
@@ -13,6 +18,14 @@ import (
 const (
 	getStatsMethod102 = "miner_getstat2"
 	getStatsMethod98  = "miner_getstat1"
+
+	// control_gpu states
+	gpuDisabled = "0"
+	gpuMainOnly = "1"
+	gpuDual     = "2"
+
+	defaultDialTimeout = 5 * time.Second
+	defaultReadTimeout = 10 * time.Second
 )
 
 // ClaymoreClient implements Client interface
@@ -22,18 +35,29 @@ type ClaymoreClient struct {
 	version      float64
 	readOnly     bool
 	failOnWrites bool
+	dialTimeout  time.Duration
+	readTimeout  time.Duration
+	dualMining   bool
 
 	ps PowerService
 }
 
 // NewClaymoreClient returns a claymore client without power monitoring
-func NewClaymoreClient(addr, password string, version float64) Client {
-	return &ClaymoreClient{addr: addr, password: password, version: version}
+func NewClaymoreClient(addr, password string, version float64) *ClaymoreClient {
+	return &ClaymoreClient{addr: addr, password: password, version: version,
+		dialTimeout: defaultDialTimeout, readTimeout: defaultReadTimeout}
 }
 
 // NewClaymoreClientWithPowerService returns a claymore client with power monitoring
-func NewClaymoreClientWithPowerService(addr, password string, version float64, ps PowerService) Client {
-	return &ClaymoreClient{addr: addr, password: password, version: version, ps: ps}
+func NewClaymoreClientWithPowerService(addr, password string, version float64, ps PowerService) *ClaymoreClient {
+	return &ClaymoreClient{addr: addr, password: password, version: version, ps: ps,
+		dialTimeout: defaultDialTimeout, readTimeout: defaultReadTimeout}
+}
+
+// SetTimeouts used when connecting to and reading a reply from the remote management interface
+func (c *ClaymoreClient) SetTimeouts(dial, read time.Duration) {
+	c.dialTimeout = dial
+	c.readTimeout = read
 }
 
 // SetReadOnly on the client
@@ -43,10 +67,11 @@ func (c *ClaymoreClient) SetReadOnly(readOnly, failOnWrites bool) {
 }
 
 type claymoreRequest struct {
-	ID       int    `json:"id"`
-	JSONRPC  string `json:"jsonrpc"`
-	Method   string `json:"method"`
-	Password string `json:"psw,omitempty"`
+	ID       int      `json:"id"`
+	JSONRPC  string   `json:"jsonrpc"`
+	Method   string   `json:"method"`
+	Params   []string `json:"params,omitempty"`
+	Password string   `json:"psw,omitempty"`
 }
 
 type claymoreResponse struct {
@@ -55,44 +80,89 @@ type claymoreResponse struct {
 	Error  string   `json:"error"`
 }
 
-func (c *ClaymoreClient) send(method string, expectReply bool) (*claymoreResponse, error) {
+func (c *ClaymoreClient) send(ctx context.Context, method string, params []string, expectReply bool) (*claymoreResponse, error) {
 	req := &claymoreRequest{
 		ID:       0,
 		JSONRPC:  "2.0",
 		Method:   method,
+		Params:   params,
 		Password: c.password,
 	}
 	b, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal claymore request: %s", err)
 	}
-	tcpAddr, err := net.ResolveTCPAddr("tcp", c.addr)
+	dialer := net.Dialer{Timeout: c.dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve tcp addr %s: %s", c.addr, err)
+		return nil, &ConnectionError{Addr: c.addr, Op: "connect to", Err: err}
 	}
-	conn, err := net.DialTCP("tcp", nil, tcpAddr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to remote addr %s: %s", c.addr, err)
+	defer conn.Close()
+
+	var deadline time.Time
+	if c.readTimeout > 0 {
+		deadline = time.Now().Add(c.readTimeout)
 	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	// Unblock any pending read or write if the context is cancelled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
 	if _, err := conn.Write(b); err != nil {
-		return nil, fmt.Errorf("failed to write to remote addr %s: %s", c.addr, err)
+		return nil, &ConnectionError{Addr: c.addr, Op: "write to", Err: err}
 	}
 
 	if expectReply {
-		reply := make([]byte, 1024)
-		n, err := conn.Read(reply)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read remote addr %s reply: %s", c.addr, err)
-		}
+		// Responses from rigs with many GPUs span multiple reads, decode until the full JSON object has arrived
 		var response claymoreResponse
-		if err := json.Unmarshal(reply[:n], &response); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal response from remote addr %s got %s: %s", c.addr, string(b[:n]), err)
+		if err := json.NewDecoder(conn).Decode(&response); err != nil {
+			// A reply which is not valid JSON still came from a reachable client
+			var netErr net.Error
+			if err == io.EOF || err == io.ErrUnexpectedEOF || errors.As(err, &netErr) {
+				return nil, &ConnectionError{Addr: c.addr, Op: "read response from", Err: err}
+			}
+			return nil, fmt.Errorf("failed to decode response from remote addr %s: %s", c.addr, err)
+		}
+		if response.Error != "" {
+			return nil, fmt.Errorf("remote addr %s returned error: %s", c.addr, response.Error)
 		}
 		return &response, nil
 	}
 	return nil, nil
 }
 
+const (
+	// claymoreMinResultFields is the number of fields returned by miner_getstat1 and claymore-xmr
+	claymoreMinResultFields = 9
+	// claymoreResultFields is the number of fields returned by miner_getstat2
+	claymoreResultFields = 15
+)
+
+// ClaymoreParseError is returned when a field of the claymore statistics response cannot be parsed
+type ClaymoreParseError struct {
+	// Field index within the response result
+	Field int
+	// Name of the field
+	Name  string
+	Value string
+	Err   error
+}
+
+// Error returns a human readable format of the parse error
+func (e *ClaymoreParseError) Error() string {
+	return fmt.Sprintf("failed to parse %s from field %d %q: %s", e.Name, e.Field, e.Value, e.Err)
+}
+
 func parseFloatFromSeparatedString(s, sep string) ([]float64, error) {
 	var res []float64
 	for _, fs := range strings.Split(s, sep) {
@@ -117,127 +187,168 @@ func parseIntFromSeparatedString(s, sep string) ([]int, error) {
 	return res, nil
 }
 
-// Stats returns current stats of the client
-//
-func (c *ClaymoreClient) Stats() (*Statistics, error) {
-	getStatMethod := getStatsMethod98
-	if c.version >= 10.2 {
-		getStatMethod = getStatsMethod102
+// parseFloatField parses a ';' separated field of the result expecting at least min values
+func parseFloatField(result []string, field int, name string, min int) ([]float64, error) {
+	values, err := parseFloatFromSeparatedString(result[field], ";")
+	if err == nil && len(values) < min {
+		err = fmt.Errorf("expected at least %d values got %d", min, len(values))
 	}
-	glog.V(1).Infof("Getting stats using claymore API '%s'", getStatMethod)
-	resp, err := c.send(getStatMethod, true)
 	if err != nil {
-		return nil, err
+		return nil, &ClaymoreParseError{Field: field, Name: name, Value: result[field], Err: err}
+	}
+	return values, nil
+}
+
+// parseHashRateField parses a ';' separated field of GPU hash rates, GPUs disabled with control_gpu are reported
+// as "off" and parsed as 0
+func parseHashRateField(result []string, field int, name string) ([]float64, error) {
+	var values []float64
+	for _, fs := range strings.Split(result[field], ";") {
+		if fs == "off" {
+			values = append(values, 0)
+			continue
+		}
+		f, err := strconv.ParseFloat(fs, 64)
+		if err != nil {
+			return nil, &ClaymoreParseError{Field: field, Name: name, Value: result[field], Err: err}
+		}
+		values = append(values, f)
+	}
+	return values, nil
+}
+
+// parseIntField parses a ';' separated field of the result
+func parseIntField(result []string, field int, name string) ([]int, error) {
+	values, err := parseIntFromSeparatedString(result[field], ";")
+	if err != nil {
+		return nil, &ClaymoreParseError{Field: field, Name: name, Value: result[field], Err: err}
+	}
+	return values, nil
+}
+
+// parseStats converts the result of miner_getstat1 or miner_getstat2 into Statistics
+func parseStats(result []string) (*Statistics, error) {
+	// Add ability to also use claymore-xmr miner... it only returns the miner_getstat1 fields
+	// The first missing field is reported for truncated responses
+	if len(result) < claymoreMinResultFields {
+		return nil, &ClaymoreParseError{Field: len(result), Name: "result", Value: strings.Join(result, ","),
+			Err: fmt.Errorf("expected at least %d fields got %d", claymoreMinResultFields, len(result))}
+	}
+	if len(result) > claymoreMinResultFields && len(result) < claymoreResultFields {
+		return nil, &ClaymoreParseError{Field: len(result), Name: "result", Value: strings.Join(result, ","),
+			Err: fmt.Errorf("expected %d fields got %d", claymoreResultFields, len(result))}
 	}
-	glog.V(1).Infof("claymore response: %+v", resp)
 	stats := &Statistics{
-		Version: resp.Result[0],
+		Version: result[0],
 	}
-	miningPools := strings.Split(resp.Result[7], ";")
+	miningPools := strings.Split(result[7], ";")
 	stats.MainMiningPool = miningPools[0]
 	if len(miningPools) > 1 {
 		stats.AltMiningPool = miningPools[1]
 	}
 
-	runningTime, err := strconv.Atoi(resp.Result[1])
+	runningTime, err := strconv.Atoi(result[1])
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse running time from %s: %s", resp.Result[1], err)
+		return nil, &ClaymoreParseError{Field: 1, Name: "running time", Value: result[1], Err: err}
 	}
 	stats.RunningTime = runningTime
 
-	ethInfo, err := parseFloatFromSeparatedString(resp.Result[2], ";")
+	ethInfo, err := parseFloatField(result, 2, "eth info", 3)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse eth info from %s: %s", resp.Result[2], err)
+		return nil, err
 	}
 	stats.MainHashRate = ethInfo[0]
 	stats.MainShares = int(ethInfo[1])
 	stats.MainRejectedShares = int(ethInfo[2])
 
-	ethHashRates, err := parseFloatFromSeparatedString(resp.Result[3], ";")
+	ethHashRates, err := parseHashRateField(result, 3, "eth gpu hashrates")
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse eth gpu hashrates from %s: %s", resp.Result[3], err)
+		return nil, err
 	}
 	stats.MainGpuHashRate = ethHashRates
-	altInfo, err := parseFloatFromSeparatedString(resp.Result[4], ";")
+
+	// Alt fields are "off" when not dual mining
+	altInfo, err := parseFloatField(result, 4, "alt info", 3)
 	if err == nil {
 		stats.AltHashRate = altInfo[0]
 		stats.AltShares = int(altInfo[1])
 		stats.AltRejectedShares = int(altInfo[2])
 	}
-
-	altHashRates, err := parseFloatFromSeparatedString(resp.Result[5], ";")
-	if err == nil {
-		stats.AltGpuHashRate = altHashRates
+	if stats.AltMiningPool != "" {
+		if stats.AltGpuHashRate, err = parseHashRateField(result, 5, "alt gpu hashrates"); err != nil {
+			return nil, err
+		}
 	}
 
-	gpuInfo := strings.Split(resp.Result[6], ";")
+	gpuInfo, err := parseFloatField(result, 6, "gpu temperatures and fan percents", 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(gpuInfo)%2 != 0 {
+		return nil, &ClaymoreParseError{Field: 6, Name: "gpu temperatures and fan percents", Value: result[6],
+			Err: fmt.Errorf("expected pairs of values got %d values", len(gpuInfo))}
+	}
 	for i := 0; i < len(gpuInfo); i += 2 {
-		temp, err := strconv.ParseFloat(gpuInfo[i], 64)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse gpu temp from %s: %s", gpuInfo[i], err)
-		}
-		fanPercent, err := strconv.ParseFloat(gpuInfo[i+1], 64)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse fan percent from %s: %s", gpuInfo[i], err)
-		}
-		stats.GpuTemperatures = append(stats.GpuTemperatures, temp)
-		stats.GpuFanPercents = append(stats.GpuFanPercents, fanPercent)
+		stats.GpuTemperatures = append(stats.GpuTemperatures, gpuInfo[i])
+		stats.GpuFanPercents = append(stats.GpuFanPercents, gpuInfo[i+1])
 	}
-	miningInfo, err := parseFloatFromSeparatedString(resp.Result[8], ";")
+
+	miningInfo, err := parseFloatField(result, 8, "mining info", 4)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse mining info from %s: %s", resp.Result[8], err)
+		return nil, err
 	}
 	stats.MainInvalidShares = int(miningInfo[0])
 	stats.MainPoolSwitches = int(miningInfo[1])
 	stats.AltInvalidShares = int(miningInfo[2])
 	stats.AltPoolSwitches = int(miningInfo[3])
 
-	// Add ability to also use claymore-xmr miner... it seems to only have a length of 8
-	if len(resp.Result) > 9 {
-		gpuEthAccepted, err := parseIntFromSeparatedString(resp.Result[9], ";")
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse gpu eth accepted from %s: %s", resp.Result[9], err)
-		}
-		stats.MainGpuShares = gpuEthAccepted
-		gpuEthRejected, err := parseIntFromSeparatedString(resp.Result[10], ";")
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse gpu eth rejected from %s: %s", resp.Result[10], err)
-		}
-		stats.MainGpuRejectedShares = gpuEthRejected
-		gpuEthInvalid, err := parseIntFromSeparatedString(resp.Result[11], ";")
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse gpu gpu eth invalid from %s: %s", resp.Result[11], err)
-		}
-		stats.MainGpuInvalidShares = gpuEthInvalid
-		gpuAltAccepted, err := parseIntFromSeparatedString(resp.Result[12], ";")
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse gpu alt accepted from %s: %s", resp.Result[12], err)
-		}
-		stats.AltGpuShares = gpuAltAccepted
-		gpuAltRejected, err := parseIntFromSeparatedString(resp.Result[13], ";")
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse gpu alt rejected from %s: %s", resp.Result[13], err)
-		}
-		stats.AltGpuRejectedShares = gpuAltRejected
-		gpuAltInvalid, err := parseIntFromSeparatedString(resp.Result[14], ";")
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse gpu alt invalid from %s: %s", resp.Result[14], err)
-		}
-		stats.AltGpuInvalidShares = gpuAltInvalid
-		if c.ps != nil {
-			powerStats, err := c.ps.State()
-			if err != nil {
-				return nil, err
-			}
-			stats.PowerState = powerStats
-		}
+	if len(result) < claymoreResultFields {
+		return stats, nil
+	}
+	if stats.MainGpuShares, err = parseIntField(result, 9, "gpu eth accepted"); err != nil {
+		return nil, err
+	}
+	if stats.MainGpuRejectedShares, err = parseIntField(result, 10, "gpu eth rejected"); err != nil {
+		return nil, err
+	}
+	if stats.MainGpuInvalidShares, err = parseIntField(result, 11, "gpu eth invalid"); err != nil {
+		return nil, err
+	}
+	if stats.AltGpuShares, err = parseIntField(result, 12, "gpu alt accepted"); err != nil {
+		return nil, err
 	}
+	if stats.AltGpuRejectedShares, err = parseIntField(result, 13, "gpu alt rejected"); err != nil {
+		return nil, err
+	}
+	if stats.AltGpuInvalidShares, err = parseIntField(result, 14, "gpu alt invalid"); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Stats returns current stats of the client
+func (c *ClaymoreClient) Stats(ctx context.Context) (*Statistics, error) {
+	getStatMethod := getStatsMethod98
+	if c.version >= 10.2 {
+		getStatMethod = getStatsMethod102
+	}
+	glog.V(1).Infof("Getting stats using claymore API '%s'", getStatMethod)
+	resp, err := c.send(ctx, getStatMethod, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	glog.V(1).Infof("claymore response: %+v", resp)
+	stats, err := parseStats(resp.Result)
+	if err != nil {
+		return nil, err
+	}
+	c.dualMining = stats.AltMiningPool != ""
 	glog.V(3).Infof("[%s] Stats: %+v", c.IP(), stats)
 	return stats, nil
 }
 
 // Reboot the client using the remote management interface and the command `miner_reboot`
-func (c *ClaymoreClient) Reboot() error {
+func (c *ClaymoreClient) Reboot(ctx context.Context) error {
 	if c.readOnly {
 		if c.failOnWrites {
 			return fmt.Errorf("client is read only")
@@ -248,7 +359,7 @@ func (c *ClaymoreClient) Reboot() error {
 		return fmt.Errorf("remote console does not have a password set and is insecure, " +
 			"please set a password to use this functionality")
 	}
-	_, err := c.send("miner_reboot", false)
+	_, err := c.send(ctx, "miner_reboot", nil, false)
 	if err != nil {
 		return err
 	}
@@ -256,7 +367,44 @@ func (c *ClaymoreClient) Reboot() error {
 }
 
 // Restart the client using the remote management interface and the command `miner_restart`
-func (c *ClaymoreClient) Restart() error {
+func (c *ClaymoreClient) Restart(ctx context.Context) error {
+	if c.readOnly {
+		if c.failOnWrites {
+			return fmt.Errorf("client is read only")
+		}
+		return nil
+	}
+	if c.password == "" {
+		return fmt.Errorf("remote console does not have a password set and is insecure, " +
+			"please set a password to use this functionality")
+	}
+	_, err := c.send(ctx, "miner_restart", nil, false)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetGPUEnabled enables or disables the GPU at the given index using the command `control_gpu`, -1 for all GPUs.
+// GPUs are enabled in dual mining mode if the last statistics reported an alt mining pool.
+func (c *ClaymoreClient) SetGPUEnabled(ctx context.Context, gpu int, enabled bool) error {
+	state := gpuDisabled
+	if enabled {
+		state = gpuMainOnly
+		if c.dualMining {
+			state = gpuDual
+		}
+	}
+	return c.controlGPU(ctx, gpu, state)
+}
+
+// SetGPUMainOnly switches the GPU at the given index to mining the main algorithm only using the command
+// `control_gpu`, -1 for all GPUs.
+func (c *ClaymoreClient) SetGPUMainOnly(ctx context.Context, gpu int) error {
+	return c.controlGPU(ctx, gpu, gpuMainOnly)
+}
+
+func (c *ClaymoreClient) controlGPU(ctx context.Context, gpu int, state string) error {
 	if c.readOnly {
 		if c.failOnWrites {
 			return fmt.Errorf("client is read only")
@@ -267,20 +415,84 @@ func (c *ClaymoreClient) Restart() error {
 		return fmt.Errorf("remote console does not have a password set and is insecure, " +
 			"please set a password to use this functionality")
 	}
-	_, err := c.send("miner_restart", false)
+	_, err := c.send(ctx, "control_gpu", []string{strconv.Itoa(gpu), state}, false)
+	return err
+}
+
+// ReadConfigFile returns the contents of a miner file such as epools.txt using the command `miner_getfile`
+func (c *ClaymoreClient) ReadConfigFile(ctx context.Context, name string) ([]byte, error) {
+	if c.password == "" {
+		return nil, fmt.Errorf("remote console does not have a password set and is insecure, " +
+			"please set a password to use this functionality")
+	}
+	if err := validateConfigFileName(name); err != nil {
+		return nil, err
+	}
+	resp, err := c.send(ctx, "miner_getfile", []string{name}, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Result) < 2 {
+		return nil, fmt.Errorf("expected file name and contents in response got %d fields", len(resp.Result))
+	}
+	data, err := hex.DecodeString(resp.Result[1])
 	if err != nil {
+		return nil, fmt.Errorf("failed to decode contents of %s: %s", name, err)
+	}
+	return data, nil
+}
+
+// WriteConfigFile replaces a miner file such as epools.txt using the command `miner_file`. Claymore only
+// reads its configuration files on start, so the miner must be restarted for the change to take effect.
+func (c *ClaymoreClient) WriteConfigFile(ctx context.Context, name string, data []byte) error {
+	if c.readOnly {
+		if c.failOnWrites {
+			return fmt.Errorf("client is read only")
+		}
+		return nil
+	}
+	if c.password == "" {
+		return fmt.Errorf("remote console does not have a password set and is insecure, " +
+			"please set a password to use this functionality")
+	}
+	if err := validateConfigFileName(name); err != nil {
 		return err
 	}
+	_, err := c.send(ctx, "miner_file", []string{name, hex.EncodeToString(data)}, false)
+	return err
+}
+
+// validateConfigFileName only allows files within the miner directory
+func validateConfigFileName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid miner file name %q, only files in the miner directory can be used", name)
+	}
 	return nil
 }
 
+// PowerState returns the power state of the client read from its power service
+func (c *ClaymoreClient) PowerState(ctx context.Context) (*PowerState, error) {
+	if c.ps == nil {
+		return nil, fmt.Errorf("power state not available on this client, no power service available")
+	}
+	var state *PowerState
+	err := withContext(ctx, func() (err error) {
+		state, err = c.ps.State()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
 // PowerCycleEnabled bool if client has a PowerService
 func (c *ClaymoreClient) PowerCycleEnabled() bool {
 	return c.ps != nil
 }
 
 // PowerCycle the client using an external smart plug power service
-func (c *ClaymoreClient) PowerCycle() error {
+func (c *ClaymoreClient) PowerCycle(ctx context.Context) error {
 	if c.ReadOnly() {
 		if c.failOnWrites {
 			return fmt.Errorf("client is read only")
@@ -290,7 +502,35 @@ func (c *ClaymoreClient) PowerCycle() error {
 	if !c.PowerCycleEnabled() {
 		return fmt.Errorf("power cycle not enabled on this client, no power service available")
 	}
-	return c.ps.PowerCycle()
+	return withContext(ctx, c.ps.PowerCycle)
+}
+
+// PowerOff the client using an external smart plug power service
+func (c *ClaymoreClient) PowerOff(ctx context.Context) error {
+	if c.ReadOnly() {
+		if c.failOnWrites {
+			return fmt.Errorf("client is read only")
+		}
+		return nil
+	}
+	if !c.PowerCycleEnabled() {
+		return fmt.Errorf("power off not enabled on this client, no power service available")
+	}
+	return withContext(ctx, c.ps.Off)
+}
+
+// PowerOn the client using an external smart plug power service
+func (c *ClaymoreClient) PowerOn(ctx context.Context) error {
+	if c.ReadOnly() {
+		if c.failOnWrites {
+			return fmt.Errorf("client is read only")
+		}
+		return nil
+	}
+	if !c.PowerCycleEnabled() {
+		return fmt.Errorf("power on not enabled on this client, no power service available")
+	}
+	return withContext(ctx, c.ps.On)
 }
 
 // ReadOnly flag if client is in read only mode
@@ -0,0 +1,284 @@
+package miningmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Recorded miner_getstat1 and miner_getstat2 results
+var (
+	// Claymore 9.x miner_getstat1 mining ETH only
+	claymore9Result = []string{
+		"9.3 - ETH", "21", "182724;51;0", "30502;30457;30297;30481;30479;30505", "0;0;0", "off;off;off;off;off;off",
+		"53;71;57;67;61;72;55;70;59;71;61;70", "eth-eu1.nanopool.org:9999", "0;0;0;0",
+	}
+	// Claymore 10.x miner_getstat1 dual mining ETH and Siacoin
+	claymore10DualResult = []string{
+		"10.0 - ETH", "83", "150060;152;1", "25041;25008;25028;25012;25002;24969", "4502352;1287;3",
+		"750414;750413;750415;750406;750396;750708", "62;50;68;65;66;63;64;61;60;55;63;60",
+		"eth-us-east1.nanopool.org:9999;sia-us-east1.nanopool.org:7777", "2;1;4;0",
+	}
+	// Claymore 15.x miner_getstat2 with per GPU shares
+	claymore15Result = []string{
+		"15.0 - ETH", "1410", "144713;3275;2", "28929;28927;28928;28929;28929", "0;0;0", "off;off;off;off;off",
+		"59;45;61;50;60;48;58;46;62;51", "eu1.ethermine.org:4444", "1;2;0;0", "655;656;654;655;655", "0;1;0;1;0",
+		"0;0;1;0;0", "0;0;0;0;0", "0;0;0;0;0", "0;0;0;0;0",
+	}
+	// claymore-xmr only returns the miner_getstat1 fields
+	claymoreXMRResult = []string{
+		"9.7 - XMR", "49", "4190;33;0", "697;698;697;700;697;699", "0;0;0", "off;off;off;off;off;off",
+		"60;36;65;41;63;38;64;40;62;39;61;37", "xmr-eu1.nanopool.org:14444", "0;0;0;0",
+	}
+)
+
+func TestParseStats(t *testing.T) {
+	tests := []struct {
+		name        string
+		result      []string
+		version     string
+		runningTime int
+		hashRate    float64
+		gpus        int
+		altPool     string
+		altHashRate float64
+		gpuShares   []int
+	}{
+		{"claymore 9.x", claymore9Result, "9.3 - ETH", 21, 182724, 6, "", 0, nil},
+		{"claymore 10.x dual", claymore10DualResult, "10.0 - ETH", 83, 150060, 6, "sia-us-east1.nanopool.org:7777", 4502352, nil},
+		{"claymore 15.x", claymore15Result, "15.0 - ETH", 1410, 144713, 5, "", 0, []int{655, 656, 654, 655, 655}},
+		{"claymore-xmr", claymoreXMRResult, "9.7 - XMR", 49, 4190, 6, "", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := parseStats(tt.result)
+			if err != nil {
+				t.Fatalf("parseStats returned error: %s", err)
+			}
+			if stats.Version != tt.version {
+				t.Errorf("Version = %q, want %q", stats.Version, tt.version)
+			}
+			if stats.RunningTime != tt.runningTime {
+				t.Errorf("RunningTime = %d, want %d", stats.RunningTime, tt.runningTime)
+			}
+			if stats.MainHashRate != tt.hashRate {
+				t.Errorf("MainHashRate = %0.2f, want %0.2f", stats.MainHashRate, tt.hashRate)
+			}
+			if len(stats.MainGpuHashRate) != tt.gpus || len(stats.GpuTemperatures) != tt.gpus || len(stats.GpuFanPercents) != tt.gpus {
+				t.Errorf("got %d hash rates, %d temperatures and %d fan percents, want %d GPUs",
+					len(stats.MainGpuHashRate), len(stats.GpuTemperatures), len(stats.GpuFanPercents), tt.gpus)
+			}
+			if stats.AltMiningPool != tt.altPool {
+				t.Errorf("AltMiningPool = %q, want %q", stats.AltMiningPool, tt.altPool)
+			}
+			if stats.AltHashRate != tt.altHashRate {
+				t.Errorf("AltHashRate = %0.2f, want %0.2f", stats.AltHashRate, tt.altHashRate)
+			}
+			if len(stats.MainGpuShares) != len(tt.gpuShares) {
+				t.Fatalf("MainGpuShares = %v, want %v", stats.MainGpuShares, tt.gpuShares)
+			}
+			for i := range tt.gpuShares {
+				if stats.MainGpuShares[i] != tt.gpuShares[i] {
+					t.Errorf("MainGpuShares = %v, want %v", stats.MainGpuShares, tt.gpuShares)
+				}
+			}
+		})
+	}
+}
+
+func TestParseStatsFields(t *testing.T) {
+	stats, err := parseStats(claymore10DualResult)
+	if err != nil {
+		t.Fatalf("parseStats returned error: %s", err)
+	}
+	if stats.MainShares != 152 || stats.MainRejectedShares != 1 {
+		t.Errorf("main shares = %d/%d, want 152/1", stats.MainShares, stats.MainRejectedShares)
+	}
+	if stats.AltShares != 1287 || stats.AltRejectedShares != 3 {
+		t.Errorf("alt shares = %d/%d, want 1287/3", stats.AltShares, stats.AltRejectedShares)
+	}
+	if stats.MainInvalidShares != 2 || stats.MainPoolSwitches != 1 || stats.AltInvalidShares != 4 || stats.AltPoolSwitches != 0 {
+		t.Errorf("mining info = %d;%d;%d;%d, want 2;1;4;0",
+			stats.MainInvalidShares, stats.MainPoolSwitches, stats.AltInvalidShares, stats.AltPoolSwitches)
+	}
+	if stats.GpuTemperatures[2] != 66 || stats.GpuFanPercents[2] != 63 {
+		t.Errorf("GPU 2 temperature and fan = %0.0f;%0.0f, want 66;63", stats.GpuTemperatures[2], stats.GpuFanPercents[2])
+	}
+}
+
+func TestParseStatsOffFields(t *testing.T) {
+	stats, err := parseStats(claymore9Result)
+	if err != nil {
+		t.Fatalf("parseStats returned error: %s", err)
+	}
+	if stats.AltHashRate != 0 || stats.AltGpuHashRate != nil {
+		t.Errorf("alt fields = %0.2f %v, want unset when \"off\"", stats.AltHashRate, stats.AltGpuHashRate)
+	}
+}
+
+func TestParseStatsDisabledGPU(t *testing.T) {
+	result := append([]string{}, claymore10DualResult...)
+	result[3] = "25041;off;25028;25012;25002;24969"
+	result[5] = "750414;off;750415;750406;750396;750708"
+	stats, err := parseStats(result)
+	if err != nil {
+		t.Fatalf("parseStats returned error: %s", err)
+	}
+	if len(stats.MainGpuHashRate) != 6 || stats.MainGpuHashRate[1] != 0 {
+		t.Errorf("MainGpuHashRate = %v, want GPU 1 at 0", stats.MainGpuHashRate)
+	}
+	if len(stats.AltGpuHashRate) != 6 || stats.AltGpuHashRate[1] != 0 {
+		t.Errorf("AltGpuHashRate = %v, want GPU 1 at 0", stats.AltGpuHashRate)
+	}
+}
+
+func TestParseStatsErrors(t *testing.T) {
+	// with returns a copy of the result with the field replaced
+	with := func(result []string, field int, value string) []string {
+		res := append([]string{}, result...)
+		res[field] = value
+		return res
+	}
+	tests := []struct {
+		name   string
+		result []string
+		field  int
+	}{
+		{"empty", []string{}, 0},
+		{"truncated", claymore9Result[:5], 5},
+		{"truncated getstat2", claymore15Result[:12], 12},
+		{"running time", with(claymore9Result, 1, "abc"), 1},
+		{"eth info", with(claymore9Result, 2, "182724;x;0"), 2},
+		{"eth info too short", with(claymore9Result, 2, "182724;51"), 2},
+		{"eth gpu hashrates", with(claymore9Result, 3, "30502;;30297"), 3},
+		{"gpu temperatures", with(claymore9Result, 6, "53;71;x;67"), 6},
+		{"gpu temperatures not paired", with(claymore9Result, 6, "53;71;57"), 6},
+		{"mining info", with(claymore9Result, 8, "0;0;0"), 8},
+		{"gpu eth accepted", with(claymore15Result, 9, "655;x"), 9},
+		{"gpu eth rejected", with(claymore15Result, 10, "0;x"), 10},
+		{"gpu eth invalid", with(claymore15Result, 11, "0;x"), 11},
+		{"gpu alt accepted", with(claymore15Result, 12, "0;x"), 12},
+		{"gpu alt rejected", with(claymore15Result, 13, "0;x"), 13},
+		{"gpu alt invalid", with(claymore15Result, 14, "0;x"), 14},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseStats(tt.result)
+			if err == nil {
+				t.Fatal("parseStats returned no error")
+			}
+			parseErr, ok := err.(*ClaymoreParseError)
+			if !ok {
+				t.Fatalf("parseStats returned %T, want *ClaymoreParseError: %s", err, err)
+			}
+			if parseErr.Field != tt.field {
+				t.Errorf("Field = %d, want %d: %s", parseErr.Field, tt.field, err)
+			}
+		})
+	}
+}
+
+func TestClaymoreClientStatsSplitReads(t *testing.T) {
+	var hashRates, temps, shares []string
+	for i := 0; i < 13; i++ {
+		hashRates = append(hashRates, "30502")
+		temps = append(temps, "61", "70")
+		shares = append(shares, "655")
+	}
+	zeros := strings.TrimSuffix(strings.Repeat("0;", 13), ";")
+	result := []string{
+		"15.0 - ETH", "1410", "396526;8515;0", strings.Join(hashRates, ";"), "0;0;0", "off", strings.Join(temps, ";"),
+		"eu1.ethermine.org:4444", "0;0;0;0", strings.Join(shares, ";"), zeros, zeros, zeros, zeros, zeros,
+	}
+	reply, err := json.Marshal(claymoreResponse{ID: 0, Result: result})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var req claymoreRequest
+		if err := json.NewDecoder(conn).Decode(&req); err != nil || req.Method != getStatsMethod102 {
+			return
+		}
+		// Write the reply in small chunks so it spans several reads
+		for i := 0; i < len(reply); i += 64 {
+			end := i + 64
+			if end > len(reply) {
+				end = len(reply)
+			}
+			conn.Write(reply[i:end])
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	c := NewClaymoreClient(l.Addr().String(), "", 15.0)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stats, err := c.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats returned error: %s", err)
+	}
+	if len(stats.MainGpuHashRate) != 13 || len(stats.GpuTemperatures) != 13 || len(stats.MainGpuShares) != 13 {
+		t.Errorf("got %d hash rates, %d temperatures and %d shares, want 13 GPUs",
+			len(stats.MainGpuHashRate), len(stats.GpuTemperatures), len(stats.MainGpuShares))
+	}
+}
+
+func TestClaymoreClientStatsConnectionError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	c := NewClaymoreClient(addr, "", 15.0)
+	_, err = c.Stats(context.Background())
+	if _, ok := err.(*ConnectionError); !ok {
+		t.Fatalf("Stats returned %T, want *ConnectionError: %s", err, err)
+	}
+}
+
+func TestClaymoreClientStatsInvalidReply(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var req claymoreRequest
+		if err := json.NewDecoder(conn).Decode(&req); err != nil {
+			return
+		}
+		conn.Write([]byte("<html>not a claymore reply</html>\n"))
+	}()
+
+	c := NewClaymoreClient(l.Addr().String(), "", 15.0)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = c.Stats(ctx)
+	if err == nil {
+		t.Fatal("Stats returned no error")
+	}
+	if _, ok := err.(*ConnectionError); ok {
+		t.Errorf("Stats returned a *ConnectionError for an invalid reply: %s", err)
+	}
+}
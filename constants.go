@@ -9,4 +9,37 @@ const (
 	REBOOTING
 	// STOPPED state of the monitor, no longer checking on the clients state
 	STOPPED
+	// RESTARTING state of the monitor, something went wrong and the monitor will attempt to restart the mining software
+	RESTARTING
+	// VERIFYING state of the monitor, an action was taken and the monitor is waiting for the client to recover
+	VERIFYING
+	// QUARANTINED state of the monitor, recovering the client failed too many times and no further actions are
+	// taken until the client is acknowledged
+	QUARANTINED
+	// SHUTDOWN state of the monitor, the client overheated and is powered off until it has cooled down or is resumed
+	SHUTDOWN
 )
+
+// stateName returns the human readable name of a monitor state
+func stateName(state int) string {
+	switch state {
+	case POWERCYCLING:
+		return "POWERCYCLING"
+	case RUNNING:
+		return "RUNNING"
+	case REBOOTING:
+		return "REBOOTING"
+	case STOPPED:
+		return "STOPPED"
+	case RESTARTING:
+		return "RESTARTING"
+	case VERIFYING:
+		return "VERIFYING"
+	case QUARANTINED:
+		return "QUARANTINED"
+	case SHUTDOWN:
+		return "SHUTDOWN"
+	default:
+		return "UNKNOWN"
+	}
+}
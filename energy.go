@@ -0,0 +1,128 @@
+package miningmonitor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxEnergySampleGap is the longest time between two power samples that will be integrated, longer gaps
+// are treated as unknown usage rather than assuming the power stayed the same.
+const maxEnergySampleGap = 10 * time.Minute
+
+// Tariff returns the price of electricity per kWh at the given time
+type Tariff interface {
+	Rate(t time.Time) float64
+}
+
+// FlatTariff charges the same rate per kWh at all times
+type FlatTariff float64
+
+// Rate per kWh
+func (f FlatTariff) Rate(t time.Time) float64 {
+	return float64(f)
+}
+
+// TimeOfUsePeriod is a rate per kWh applied between Start and End, both offsets from midnight.
+// A period with End before Start wraps around midnight.
+type TimeOfUsePeriod struct {
+	Start time.Duration
+	End   time.Duration
+	Rate  float64
+}
+
+// TimeOfUseTariff charges a rate depending on the time of day, falling back to Default outside all periods
+type TimeOfUseTariff struct {
+	Default float64
+	Periods []TimeOfUsePeriod
+}
+
+// NewTimeOfUseTariff returns a Tariff using the first period matching the time of day or the default rate
+func NewTimeOfUseTariff(defaultRate float64, periods ...TimeOfUsePeriod) Tariff {
+	return &TimeOfUseTariff{Default: defaultRate, Periods: periods}
+}
+
+// Rate per kWh at the given time
+func (t *TimeOfUseTariff) Rate(at time.Time) float64 {
+	for _, p := range t.Periods {
+		if timeOfDayIn(at, p.Start, p.End) {
+			return p.Rate
+		}
+	}
+	return t.Default
+}
+
+// timeOfDayIn returns true if the time of day is between start and end offsets from midnight, wrapping around
+// midnight if end is before start
+func timeOfDayIn(at time.Time, start, end time.Duration) bool {
+	midnight := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	offset := at.Sub(midnight)
+	if start <= end {
+		return offset >= start && offset < end
+	}
+	return offset >= start || offset < end
+}
+
+// EnergyReport is the energy used and its cost for the current day and month
+type EnergyReport struct {
+	Power     float64
+	Day       time.Time
+	DayKWh    float64
+	DayCost   float64
+	Month     time.Time
+	MonthKWh  float64
+	MonthCost float64
+}
+
+// String human readable format of the energy report
+func (r EnergyReport) String() string {
+	return fmt.Sprintf("Power: %0.2fW\nToday (%s): %0.3fkWh, cost %0.2f\nThis month (%s): %0.3fkWh, cost %0.2f",
+		r.Power, r.Day.Format("2006-01-02"), r.DayKWh, r.DayCost, r.Month.Format("2006-01"), r.MonthKWh, r.MonthCost)
+}
+
+// energyMeter integrates the power samples of a client into energy used
+type energyMeter struct {
+	mu         sync.Mutex
+	lastSample time.Time
+	report     EnergyReport
+}
+
+// record a power sample in watts taken at the given time, the cost is calculated using the tariff if not nil
+func (e *energyMeter) record(at time.Time, power float64, tariff Tariff) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	month := time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, at.Location())
+	if !day.Equal(e.report.Day) {
+		e.report.Day = day
+		e.report.DayKWh = 0
+		e.report.DayCost = 0
+	}
+	if !month.Equal(e.report.Month) {
+		e.report.Month = month
+		e.report.MonthKWh = 0
+		e.report.MonthCost = 0
+	}
+
+	gap := at.Sub(e.lastSample)
+	if !e.lastSample.IsZero() && gap > 0 && gap <= maxEnergySampleGap {
+		kWh := (e.report.Power + power) / 2 * gap.Hours() / 1000
+		cost := 0.0
+		if tariff != nil {
+			cost = kWh * tariff.Rate(at.Add(-gap/2))
+		}
+		e.report.DayKWh += kWh
+		e.report.DayCost += cost
+		e.report.MonthKWh += kWh
+		e.report.MonthCost += cost
+	}
+	e.lastSample = at
+	e.report.Power = power
+}
+
+// Report returns the energy used so far
+func (e *energyMeter) Report() EnergyReport {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.report
+}
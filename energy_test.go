@@ -0,0 +1,61 @@
+package miningmonitor
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTimeOfDayIn(t *testing.T) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2018, 1, 15, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name       string
+		at         time.Time
+		start, end time.Duration
+		want       bool
+	}{
+		{"within", at(12, 0), 9 * time.Hour, 17 * time.Hour, true},
+		{"at start", at(9, 0), 9 * time.Hour, 17 * time.Hour, true},
+		{"at end", at(17, 0), 9 * time.Hour, 17 * time.Hour, false},
+		{"before", at(8, 59), 9 * time.Hour, 17 * time.Hour, false},
+		{"wrapping before midnight", at(23, 0), 22 * time.Hour, 6 * time.Hour, true},
+		{"wrapping after midnight", at(2, 0), 22 * time.Hour, 6 * time.Hour, true},
+		{"wrapping outside", at(12, 0), 22 * time.Hour, 6 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timeOfDayIn(tt.at, tt.start, tt.end); got != tt.want {
+				t.Errorf("timeOfDayIn = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnergyMeterRecord(t *testing.T) {
+	e := &energyMeter{}
+	start := time.Date(2018, 1, 31, 23, 50, 0, 0, time.UTC)
+	e.record(start, 1000, FlatTariff(0.1))
+	e.record(start.Add(6*time.Minute), 1000, FlatTariff(0.1))
+	r := e.Report()
+	if math.Abs(r.DayKWh-0.1) > 1e-9 || math.Abs(r.DayCost-0.01) > 1e-9 || math.Abs(r.MonthKWh-0.1) > 1e-9 {
+		t.Errorf("report = %+v, want 0.1kWh costing 0.01 today and this month", r)
+	}
+
+	// The day and month roll over at midnight
+	e.record(start.Add(12*time.Minute), 1000, FlatTariff(0.1))
+	r = e.Report()
+	if !r.Day.Equal(time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)) || !r.Month.Equal(time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("report day %v month %v, want 2018-02-01", r.Day, r.Month)
+	}
+	if math.Abs(r.DayKWh-0.1) > 1e-9 || math.Abs(r.MonthKWh-0.1) > 1e-9 {
+		t.Errorf("report = %+v, want 0.1kWh after the rollover", r)
+	}
+
+	// Gaps longer than maxEnergySampleGap are not integrated
+	e.record(start.Add(12*time.Minute+maxEnergySampleGap+time.Minute), 1000, FlatTariff(0.1))
+	if got := e.Report().DayKWh; math.Abs(got-0.1) > 1e-9 {
+		t.Errorf("DayKWh after a gap = %0.3f, want 0.1", got)
+	}
+}
@@ -0,0 +1,162 @@
+package miningmonitor
+
+import (
+	"fmt"
+	"time"
+)
+
+// Action taken on a client to recover it
+type Action int
+
+const (
+	// RestartAction restarts the mining software on the client
+	RestartAction Action = iota
+	// RebootAction reboots the client
+	RebootAction
+	// PowerCycleAction power cycles the client using its power service
+	PowerCycleAction
+)
+
+// String human readable name of the action
+func (a Action) String() string {
+	switch a {
+	case RestartAction:
+		return "Restart"
+	case RebootAction:
+		return "Reboot"
+	case PowerCycleAction:
+		return "Power Cycle"
+	default:
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+}
+
+// past tense of the action used in notifications
+func (a Action) past() string {
+	switch a {
+	case RestartAction:
+		return "restarted"
+	case PowerCycleAction:
+		return "power cycled"
+	default:
+		return "rebooted"
+	}
+}
+
+// state of the monitor while the action is being taken
+func (a Action) state() int {
+	switch a {
+	case RestartAction:
+		return RESTARTING
+	case PowerCycleAction:
+		return POWERCYCLING
+	default:
+		return REBOOTING
+	}
+}
+
+// EscalationStep is a single step of the escalation policy of a client
+type EscalationStep struct {
+	Action Action
+	// Attempts of the action before escalating to the next step
+	Attempts int
+	// CheckFails is the number of failed checks before the action is taken
+	CheckFails int
+	// Cooldown after the action is taken before another action can be taken
+	Cooldown time.Duration
+}
+
+// String human readable format of an escalation step
+func (s EscalationStep) String() string {
+	return fmt.Sprintf("%s x%d after %d failed checks, cooldown %v", s.Action, s.Attempts, s.CheckFails, s.Cooldown)
+}
+
+// NewEscalationStep returns a step taking the action attempts times, each after checkFails failed checks
+// and waiting cooldown before the next action.
+func NewEscalationStep(action Action, attempts, checkFails int, cooldown time.Duration) EscalationStep {
+	return EscalationStep{Action: action, Attempts: attempts, CheckFails: checkFails, Cooldown: cooldown}
+}
+
+// NewEscalation returns an escalation policy of restarting the miner, then rebooting and finally power cycling
+// the client, each taken the given number of attempts. Steps with 0 attempts are skipped.
+func NewEscalation(restarts, reboots, powerCycles, checkFails int, cooldown time.Duration) []EscalationStep {
+	var steps []EscalationStep
+	if restarts > 0 {
+		steps = append(steps, NewEscalationStep(RestartAction, restarts, checkFails, cooldown))
+	}
+	if reboots > 0 {
+		steps = append(steps, NewEscalationStep(RebootAction, reboots, checkFails, cooldown))
+	}
+	if powerCycles > 0 {
+		steps = append(steps, NewEscalationStep(PowerCycleAction, powerCycles, checkFails, cooldown))
+	}
+	return steps
+}
+
+// escalation tracks the progress of a client through its escalation policy
+type escalation struct {
+	steps []EscalationStep
+
+	step          int
+	attempts      int
+	cooldownUntil time.Time
+	// failed is set when the last action did not recover the client, the next step is taken without waiting for failed checks
+	failed bool
+}
+
+// current returns the step to take next, false once all steps have been exhausted
+func (e *escalation) current(c Client) (EscalationStep, bool) {
+	// Skip power cycling on clients without a power service
+	for e.step < len(e.steps) && e.steps[e.step].Action == PowerCycleAction && !c.PowerCycleEnabled() {
+		e.step++
+	}
+	if e.step >= len(e.steps) {
+		return EscalationStep{}, false
+	}
+	return e.steps[e.step], true
+}
+
+// ready returns the action to take if enough checks have failed and the cooldown of the last action has passed
+func (e *escalation) ready(c Client, failedChecks int) (Action, bool) {
+	step, ok := e.current(c)
+	if !ok || !e.failed && failedChecks < step.CheckFails || time.Now().Before(e.cooldownUntil) {
+		return 0, false
+	}
+	return step.Action, true
+}
+
+// taken records an attempt of the current step, moving to the next step once all attempts are used
+func (e *escalation) taken(c Client) {
+	step, ok := e.current(c)
+	if !ok {
+		return
+	}
+	e.cooldownUntil = time.Now().Add(step.Cooldown)
+	e.failed = false
+	e.attempts++
+	if e.attempts >= step.Attempts {
+		e.step++
+		e.attempts = 0
+	}
+}
+
+// gaveUp returns true once every step has been attempted and the client is still failing checks after the
+// cooldown of the last action
+func (e *escalation) gaveUp(c Client, failedChecks int) bool {
+	if _, ok := e.current(c); ok || len(e.steps) == 0 {
+		return false
+	}
+	return (e.failed || failedChecks >= e.steps[len(e.steps)-1].CheckFails) && !time.Now().Before(e.cooldownUntil)
+}
+
+// actionFailed records that the last action did not recover the client
+func (e *escalation) actionFailed() {
+	e.failed = true
+}
+
+// reset back to the first step once the client is healthy
+func (e *escalation) reset() {
+	e.step = 0
+	e.attempts = 0
+	e.failed = false
+}
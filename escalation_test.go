@@ -0,0 +1,99 @@
+package miningmonitor
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testClient is a Client which does nothing, used to test the monitor state
+type testClient struct {
+	ip         string
+	powerCycle bool
+}
+
+func (c *testClient) IP() string                                     { return c.ip }
+func (c *testClient) Stats(ctx context.Context) (*Statistics, error) { return &Statistics{}, nil }
+func (c *testClient) Reboot(ctx context.Context) error               { return nil }
+func (c *testClient) Restart(ctx context.Context) error              { return nil }
+func (c *testClient) PowerCycleEnabled() bool                        { return c.powerCycle }
+func (c *testClient) PowerCycle(ctx context.Context) error           { return nil }
+func (c *testClient) SetReadOnly(readOnly, failOnWrites bool)        {}
+func (c *testClient) ReadOnly() bool                                 { return false }
+
+func TestNewEscalation(t *testing.T) {
+	steps := NewEscalation(1, 0, 2, 3, time.Minute)
+	if len(steps) != 2 {
+		t.Fatalf("got %d steps, want 2: %v", len(steps), steps)
+	}
+	if steps[0].Action != RestartAction || steps[1].Action != PowerCycleAction || steps[1].Attempts != 2 {
+		t.Errorf("steps = %v, want restart x1 then power cycle x2", steps)
+	}
+}
+
+func TestEscalationReady(t *testing.T) {
+	c := &testClient{powerCycle: true}
+	e := &escalation{steps: NewEscalation(2, 1, 1, 3, 0)}
+	if _, ok := e.ready(c, 2); ok {
+		t.Error("ready before enough failed checks")
+	}
+	want := []Action{RestartAction, RestartAction, RebootAction, PowerCycleAction}
+	for i, action := range want {
+		a, ok := e.ready(c, 3)
+		if !ok || a != action {
+			t.Fatalf("attempt %d: ready = %s %t, want %s", i, a, ok, action)
+		}
+		e.taken(c)
+	}
+	if _, ok := e.ready(c, 3); ok {
+		t.Error("ready after all steps were taken")
+	}
+	if !e.gaveUp(c, 3) {
+		t.Error("did not give up after all steps were taken")
+	}
+	e.reset()
+	if a, ok := e.ready(c, 3); !ok || a != RestartAction {
+		t.Errorf("ready after reset = %s %t, want %s", a, ok, RestartAction)
+	}
+}
+
+func TestEscalationSkipsPowerCycle(t *testing.T) {
+	c := &testClient{powerCycle: false}
+	e := &escalation{steps: NewEscalation(0, 1, 1, 1, 0)}
+	e.taken(c)
+	if _, ok := e.ready(c, 1); ok {
+		t.Error("ready to power cycle a client without a power service")
+	}
+	if !e.gaveUp(c, 1) {
+		t.Error("did not give up without a power service")
+	}
+}
+
+func TestEscalationCooldown(t *testing.T) {
+	c := &testClient{}
+	e := &escalation{steps: NewEscalation(2, 0, 0, 1, time.Hour)}
+	e.taken(c)
+	if _, ok := e.ready(c, 1); ok {
+		t.Error("ready during the cooldown")
+	}
+	e.cooldownUntil = time.Now().Add(-time.Second)
+	if _, ok := e.ready(c, 1); !ok {
+		t.Error("not ready after the cooldown")
+	}
+}
+
+func TestEscalationActionFailed(t *testing.T) {
+	c := &testClient{}
+	e := &escalation{steps: NewEscalation(1, 1, 0, 5, 0)}
+	e.taken(c)
+	if _, ok := e.ready(c, 0); ok {
+		t.Error("ready without failed checks")
+	}
+	e.actionFailed()
+	if a, ok := e.ready(c, 0); !ok || a != RebootAction {
+		t.Errorf("ready after failed action = %s %t, want %s", a, ok, RebootAction)
+	}
+	if e.gaveUp(c, 0) {
+		t.Error("gave up with steps left")
+	}
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"time"
 
 	"log"
@@ -16,24 +20,68 @@ import (
 
 var (
 	debug                  = flag.Bool("debug", false, "Used for debugging to set clients to READONLY mode")
-	checkFailsBeforeReboot = flag.Int("check-fails", 3, "Number of failed checks before reboot, default 2")
-	rebootFailsBeforePower = flag.Int("reboot-fails", 3, "Number of reboot fails before we toggle power on and off")
+	checkFailsBeforeReboot = flag.Int("check-fails", 3, "Number of failed checks before each restart, reboot or power cycle")
+	restartAttempts        = flag.Int("restart-attempts", 0, "Number of miner restarts before we reboot")
+	rebootFailsBeforePower = flag.Int("reboot-fails", 3, "Number of reboots before we toggle power on and off")
+	powerCycleAttempts     = flag.Int("power-cycle-attempts", 1, "Number of power cycles before giving up")
 	statsInterval          = flag.Duration("stats-interval", 30*time.Second, "Interval to poll for statistics")
 	stateInterval          = flag.Duration("state-interval", 3*time.Second, "Time in seconds to transition monitoring states")
-	rebootInterval         = flag.Duration("reboot-interval", 5*time.Minute, "Time between successful reboots before attempting another")
-	powerCycleOnly         = flag.Bool("power-cycle-only", false, "Whether or not to skip trying to reboot with claymore and powercycle instead")
+	resetAfter             = flag.Duration("reset-after", 0, "Time checks must stay clean before failed checks are reset")
+	shutdownCooldown       = flag.Duration("shutdown-cooldown", 30*time.Minute, "Time an overheated rig stays powered off, 0 to keep it off until acknowledged")
+	rebootInterval         = flag.Duration("reboot-interval", 5*time.Minute, "Time after a restart, reboot or power cycle before attempting another")
+	verifyTimeout          = flag.Duration("verify-timeout", 10*time.Minute, "Time to wait for the client to recover after an action, 0 to not verify")
+	quarantineAfter        = flag.Int("quarantine-after", 3, "Number of failed recoveries within quarantine-window before no more actions are taken, 0 to disable")
+	quarantineWindow       = flag.Duration("quarantine-window", 6*time.Hour, "Window to count failed recoveries over")
+	maintenanceSchedule    = flag.String("maintenance-schedule", "", "Cron schedule of a maintenance window suspending actions, e.g. \"0 9 * * SAT\"")
+	maintenanceDuration    = flag.Duration("maintenance-duration", 2*time.Hour, "Duration of the maintenance window")
+	unreachableFails       = flag.Int("unreachable-fails", 5, "Number of consecutive failures to get statistics before power cycling, 0 to disable")
+	unreachableCooldown    = flag.Duration("unreachable-cooldown", 15*time.Minute, "Time after power cycling an unreachable client before power cycling it again")
+	preventiveAfter        = flag.Duration("preventive-restart-after", 0, "Running time of the miner before a preventive restart, 0 to disable")
+	preventiveReboot       = flag.Bool("preventive-reboot", false, "Reboot the rig instead of restarting the miner as a preventive restart")
+	preventiveStart        = flag.Duration("preventive-window-start", 0, "Time of day preventive restarts may start from as an offset from midnight, e.g. 2h")
+	preventiveEnd          = flag.Duration("preventive-window-end", 0, "Time of day preventive restarts may be taken until as an offset from midnight, e.g. 5h")
+	preventiveGap          = flag.Duration("preventive-restart-gap", 30*time.Minute, "Minimum time between preventive restarts of any two rigs")
+	powerCycleOnly         = flag.Bool("power-cycle-only", false, "Whether or not to skip trying to restart or reboot with claymore and powercycle instead")
+	clientTimeout          = flag.Duration("client-timeout", 30*time.Second, "Maximum time to wait for a request to the client")
+	disableFailingGPUs     = flag.Bool("disable-failing-gpus", false, "Disable GPUs exceeding thresholds before rebooting the whole rig")
+	maxDisabledGPUs        = flag.Int("max-disabled-gpus", 1, "Number of GPUs that may be disabled before rebooting instead")
 
 	claymoreAddress  = flag.String("claymore-address", "", "Address for claymore remote management interface")
 	claymorePassword = flag.String("claymore-password", "", "Password for claymore remote management interface")
 	claymoreVersion  = flag.Float64("claymore-version", 10.2, "Claymore version")
+	claymoreDial     = flag.Duration("claymore-dial-timeout", 5*time.Second, "Time to wait connecting to the claymore remote management interface")
+	claymoreRead     = flag.Duration("claymore-read-timeout", 10*time.Second, "Time to wait for a reply from the claymore remote management interface")
 
-	hashThreshold        = flag.String("hash-threshold", "<23000", "Threshold in kH/s per GPU if below will attempt reboot")
-	powerThreshold       = flag.String("power-threshold", "", "Threshold in Watts for Rig")
-	temperatureThreshold = flag.String("temp-threshold", "", "Threshold in degrees celsius for GPUs")
-	fanPercentThreshold  = flag.String("fan-threshold", ">70", "Threshold in percent for GPUs")
+	hashThreshold         = flag.String("hash-threshold", "<23000", "Threshold in kH/s per GPU if below will attempt reboot")
+	powerThreshold        = flag.String("power-threshold", "", "Threshold in Watts for Rig")
+	temperatureThreshold  = flag.String("temp-threshold", "", "Threshold in degrees celsius for GPUs")
+	tempClearThreshold    = flag.String("temp-clear-threshold", "", "Threshold in degrees celsius the GPUs must drop below once temp-threshold is exceeded")
+	criticalTempThreshold = flag.String("critical-temp-threshold", "", "Threshold in degrees celsius for GPUs if exceeded will shut down the rig")
+	fanPercentThreshold   = flag.String("fan-threshold", ">70", "Threshold in percent for GPUs")
+	fanHealthRise         = flag.Float64("fan-health-rise", 0, "Degrees celsius a GPU may heat up within fan-health-window without its fan speeding up, 0 to disable")
+	fanHealthWindow       = flag.Duration("fan-health-window", 10*time.Minute, "Window to check the fans respond to the GPU temperature over")
+	holdFor               = flag.Duration("hold-for", 0, "Time the temperature and fan thresholds must be exceeded before acting on them")
+	gpuHashThresholds     = flag.String("gpu-hash-thresholds", "", "Comma separated hash thresholds overriding hash-threshold by GPU index, e.g. \"0=<29000,3=<24000\"")
+	gpuLabels             = flag.String("gpu-labels", "", "Comma separated GPU labels by index used in messages, e.g. \"0=RX 580,1=RX 570\"")
+	efficiencyThreshold   = flag.String("efficiency-threshold", "", "Threshold in kH/s per Watt for Rig")
+	staleWindow           = flag.Duration("stale-window", 0, "Time statistics may stay the same before the miner is considered frozen, 0 to disable")
+	baselineDrop          = flag.Float64("baseline-drop", 0, "Percent the rig hash rate may drop below its baseline before attempting reboot, 0 to disable")
+	baselineWindow        = flag.Duration("baseline-window", 24*time.Hour, "Window of healthy samples the median baseline hash rate is learned from")
+	altHashThreshold      = flag.String("alt-hash-threshold", "", "Threshold in kH/s per GPU for the alt coin of dual mining rigs")
+	altRejectedThreshold  = flag.String("alt-rejected-threshold", "", "Threshold in percent of rejected alt coin shares per GPU within share-window")
+	rejectedThreshold     = flag.String("rejected-threshold", "", "Threshold in percent of rejected shares per GPU within share-window")
+	invalidThreshold      = flag.String("invalid-threshold", "", "Threshold in percent of invalid shares per GPU within share-window")
+	shareWindow           = flag.Duration("share-window", 1*time.Hour, "Window to calculate share ratios over")
+	primaryPool           = flag.String("primary-pool", "", "Pool the rig should be mining on, alerts when failed over to another pool")
+	poolSwitchThreshold   = flag.String("pool-switch-threshold", "", "Threshold of pool switches per hour")
+	allowedPools          = flag.String("allowed-pools", "", "Comma separated list of pools the rig is allowed to mine on")
+	gpuCount              = flag.Int("gpu-count", -1, "Expected number of GPUs, 0 to learn from the first healthy sample, -1 to disable")
+	wattsPerGPUThreshold  = flag.String("watts-per-gpu-threshold", "", "Threshold in Watts per hashing GPU for Rig")
 
 	hs110PlugIP = flag.String("hs110plug-ip", "", "TPLink HS110 plug IP")
 
+	sentinels = flag.String("sentinels", "", "Comma separated host:port addresses that must be reachable before taking actions, e.g. the gateway")
+
 	emailEnabled  = flag.Bool("email-enabled", true, "Enable/Disable email flag")
 	email         = flag.String("email", "", "Email to send from")
 	emailHost     = flag.String("email-host", "", "Email Host, if set will send email on events")
@@ -42,8 +90,32 @@ var (
 
 	emailMaxInterval = flag.Int("email-max-interval", 5, "Max emails to send in email-timeout duration")
 	emailTimeout     = flag.Duration("emailTimeout", 1*time.Hour, "Time between sending emails if maximum is reached")
+
+	metricsAddress        = flag.String("metrics-address", "", "Address to serve metrics on, e.g. :9090")
+	tariff                = flag.Float64("tariff", 0, "Flat electricity rate per kWh used to calculate energy cost")
+	energySummaryInterval = flag.Duration("energy-summary-interval", 0, "Interval to send an energy usage summary, 0 to disable")
 )
 
+// parseGPUMap parses a comma separated list of index=value pairs
+func parseGPUMap(s string) map[int]string {
+	res := map[int]string{}
+	if s == "" {
+		return res
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			panic(fmt.Errorf("invalid GPU value %s, expected the format index=value", pair))
+		}
+		gpu, err := strconv.Atoi(strings.TrimSpace(kv[0]))
+		if err != nil {
+			panic(fmt.Errorf("invalid GPU index %s: %s", kv[0], err))
+		}
+		res[gpu] = kv[1]
+	}
+	return res
+}
+
 func main() {
 	flag.Parse()
 	s := make(chan os.Signal, 1)
@@ -65,16 +137,33 @@ func main() {
 	ps := miningmonitor.NewHS110PowerService(*hs110PlugIP)
 	c := miningmonitor.NewClaymoreClientWithPowerService(*claymoreAddress, *claymorePassword, *claymoreVersion, ps)
 	c.SetReadOnly(*debug, true)
+	c.SetTimeouts(*claymoreDial, *claymoreRead)
 
 	// Create the monitor service
 	m := miningmonitor.NewMonitor(eventService)
+	m.SetTariff(miningmonitor.FlatTariff(*tariff))
+	m.SetEnergySummaryInterval(*energySummaryInterval)
+	m.SetPreventiveRestartGap(*preventiveGap)
+	if *sentinels != "" {
+		m.SetNetworkGuard(miningmonitor.NewNetworkGuard(strings.Split(*sentinels, ","), 5*time.Second, 0))
+	}
 
-	// Create threshold for hash rate
-	hashThreshold, err := miningmonitor.NewHashRateThreshold(*hashThreshold, true, false)
+	// Create threshold for hash rate, with per GPU overrides if set in flags
+	var hrThreshold *miningmonitor.Threshold
+	var err error
+	if *gpuHashThresholds != "" || *gpuLabels != "" {
+		hrThreshold, err = miningmonitor.NewPerGPUHashRateThreshold(miningmonitor.GPULimits{
+			Default: *hashThreshold,
+			PerGPU:  parseGPUMap(*gpuHashThresholds),
+			Labels:  parseGPUMap(*gpuLabels),
+		}, true, false)
+	} else {
+		hrThreshold, err = miningmonitor.NewHashRateThreshold(*hashThreshold, true, false)
+	}
 	if err != nil {
 		panic(err)
 	}
-	thresholds := []*miningmonitor.Threshold{hashThreshold}
+	thresholds := []*miningmonitor.Threshold{hrThreshold}
 
 	// Create power threshold if set in flags
 	if *powerThreshold != "" {
@@ -90,7 +179,22 @@ func main() {
 		if err != nil {
 			panic(err)
 		}
-		thresholds = append(thresholds, tempThreshold)
+		var tempClear *miningmonitor.Threshold
+		if *tempClearThreshold != "" {
+			tempClear, err = miningmonitor.NewTemperatureThreshold(*tempClearThreshold, true, true)
+			if err != nil {
+				panic(err)
+			}
+		}
+		thresholds = append(thresholds, miningmonitor.NewHysteresisThreshold(tempThreshold, tempClear, *holdFor))
+	}
+	// Create critical temperature threshold if set in flags
+	if *criticalTempThreshold != "" {
+		criticalThreshold, err := miningmonitor.NewCriticalTemperatureThreshold(*criticalTempThreshold, true)
+		if err != nil {
+			panic(err)
+		}
+		thresholds = append(thresholds, criticalThreshold)
 	}
 	// Create fan percent threshold if set in flags
 	if *fanPercentThreshold != "" {
@@ -98,17 +202,160 @@ func main() {
 		if err != nil {
 			panic(err)
 		}
-		thresholds = append(thresholds, fpThreshold)
+		thresholds = append(thresholds, miningmonitor.NewHysteresisThreshold(fpThreshold, nil, *holdFor))
+	}
+	// Create stale statistics threshold if set in flags
+	if *staleWindow > 0 {
+		staleThreshold, err := miningmonitor.NewStaleStatsThreshold(*staleWindow, true, true)
+		if err != nil {
+			panic(err)
+		}
+		thresholds = append(thresholds, staleThreshold)
+	}
+	// Create rejected share ratio threshold if set in flags
+	if *rejectedThreshold != "" {
+		rsThreshold, err := miningmonitor.NewRejectedShareRatioThreshold(*rejectedThreshold, *shareWindow, true, true, true)
+		if err != nil {
+			panic(err)
+		}
+		thresholds = append(thresholds, rsThreshold)
+	}
+	// Create baseline hash rate threshold if set in flags
+	if *baselineDrop > 0 {
+		baselineThreshold, err := miningmonitor.NewBaselineHashRateThreshold(*baselineDrop, *baselineWindow, true, true)
+		if err != nil {
+			panic(err)
+		}
+		thresholds = append(thresholds, baselineThreshold)
+	}
+	// Create alt coin thresholds if set in flags
+	if *altHashThreshold != "" {
+		altHash, err := miningmonitor.NewHashRateThreshold(*altHashThreshold, true, true)
+		if err != nil {
+			panic(err)
+		}
+		thresholds = append(thresholds, miningmonitor.NewAlgorithmThreshold(miningmonitor.AltAlgorithm, altHash))
+	}
+	if *altRejectedThreshold != "" {
+		altRejected, err := miningmonitor.NewRejectedShareRatioThreshold(*altRejectedThreshold, *shareWindow, true, true, true)
+		if err != nil {
+			panic(err)
+		}
+		thresholds = append(thresholds, miningmonitor.NewAlgorithmThreshold(miningmonitor.AltAlgorithm, altRejected))
+	}
+	// Create invalid share ratio threshold if set in flags
+	if *invalidThreshold != "" {
+		isThreshold, err := miningmonitor.NewInvalidShareRatioThreshold(*invalidThreshold, *shareWindow, true, true, true)
+		if err != nil {
+			panic(err)
+		}
+		thresholds = append(thresholds, isThreshold)
 	}
+	// Create pool thresholds if set in flags
+	if *primaryPool != "" {
+		pfThreshold, err := miningmonitor.NewPoolFailoverThreshold(*primaryPool, false, true)
+		if err != nil {
+			panic(err)
+		}
+		thresholds = append(thresholds, pfThreshold)
+	}
+	if *poolSwitchThreshold != "" {
+		psThreshold, err := miningmonitor.NewPoolSwitchThreshold(*poolSwitchThreshold, 1*time.Hour, false, true)
+		if err != nil {
+			panic(err)
+		}
+		thresholds = append(thresholds, psThreshold)
+	}
+	if *allowedPools != "" {
+		paThreshold, err := miningmonitor.NewPoolAllowListThreshold(strings.Split(*allowedPools, ","), false, true)
+		if err != nil {
+			panic(err)
+		}
+		thresholds = append(thresholds, paThreshold)
+	}
+	// Create GPU count threshold if set in flags
+	if *gpuCount >= 0 {
+		gcThreshold, err := miningmonitor.NewGPUCountThreshold(*gpuCount, true, true)
+		if err != nil {
+			panic(err)
+		}
+		thresholds = append(thresholds, gcThreshold)
+	}
+	// Create fan health threshold if set in flags
+	if *fanHealthRise > 0 {
+		fhThreshold, err := miningmonitor.NewFanHealthThreshold(*fanHealthRise, *fanHealthWindow, false, true)
+		if err != nil {
+			panic(err)
+		}
+		thresholds = append(thresholds, fhThreshold)
+	}
+	// Create efficiency threshold if set in flags
+	if *efficiencyThreshold != "" {
+		effThreshold, err := miningmonitor.NewEfficiencyThreshold(*efficiencyThreshold, true, true)
+		if err != nil {
+			panic(err)
+		}
+		thresholds = append(thresholds, effThreshold)
+	}
+	// Create watts per GPU threshold if set in flags
+	if *wattsPerGPUThreshold != "" {
+		wpgThreshold, err := miningmonitor.NewWattsPerGPUThreshold(*wattsPerGPUThreshold, true, true)
+		if err != nil {
+			panic(err)
+		}
+		thresholds = append(thresholds, wpgThreshold)
+	}
+	// Restart the miner, then reboot and finally power cycle while the client keeps failing checks
+	restarts, reboots := *restartAttempts, *rebootFailsBeforePower
+	if *powerCycleOnly {
+		restarts, reboots = 0, 0
+	}
+	escalation := miningmonitor.NewEscalation(restarts, reboots, *powerCycleAttempts, *checkFailsBeforeReboot, *rebootInterval)
+
 	// Add client to monitor with given thresholds
-	m.AddClient(c, miningmonitor.NewClientMonitorConfig(
-		thresholds, *checkFailsBeforeReboot, *rebootFailsBeforePower,
-		*rebootInterval, *statsInterval, *stateInterval, *powerCycleOnly,
-	))
+	config := miningmonitor.NewClientMonitorConfig(thresholds, escalation, *statsInterval, *stateInterval)
+	config.Timeout = *clientTimeout
+	config.VerifyTimeout = *verifyTimeout
+	config.ResetAfter = *resetAfter
+	config.ShutdownCooldown = *shutdownCooldown
+	// Create preventive restart if set in flags
+	if *preventiveAfter > 0 {
+		preventiveAction := miningmonitor.RestartAction
+		if *preventiveReboot {
+			preventiveAction = miningmonitor.RebootAction
+		}
+		preventive, err := miningmonitor.NewPreventiveRestart(preventiveAction, *preventiveAfter, *preventiveStart, *preventiveEnd)
+		if err != nil {
+			panic(err)
+		}
+		config.PreventiveRestart = preventive
+	}
+	config.QuarantineAfter = *quarantineAfter
+	config.QuarantineWindow = *quarantineWindow
+	config.UnreachableFailsBeforePowerCycle = *unreachableFails
+	config.UnreachableCooldown = *unreachableCooldown
+	// Create maintenance window if set in flags
+	if *maintenanceSchedule != "" {
+		window, err := miningmonitor.NewMaintenanceWindow(*maintenanceSchedule, *maintenanceDuration, true, false)
+		if err != nil {
+			panic(err)
+		}
+		config.MaintenanceWindows = append(config.MaintenanceWindows, window)
+	}
+	config.DisableFailingGPUs = *disableFailingGPUs
+	config.MaxDisabledGPUs = *maxDisabledGPUs
+	m.AddClient(c, config)
 
 	// start the monitor
 	m.Start()
 
+	// Serve metrics if an address is set in flags
+	if *metricsAddress != "" {
+		go func() {
+			glog.Info(http.ListenAndServe(*metricsAddress, m.MetricsHandler()))
+		}()
+	}
+
 	// Start goroutine to monitor stdin of the program and take actions if keys are pressed
 	go func() {
 		scanner := bufio.NewScanner(os.Stdin)
@@ -118,11 +365,15 @@ func main() {
 		}
 	}()
 
-	glog.Info("Mining Monitor running\nCommands:\nstop|s - stop the monitoring\nresume|r - resume the monitoring\ndebug|d - enable debugging\n\n")
+	glog.Info("Mining Monitor running\nCommands:\nstop|s - stop the monitoring\nresume|r - resume the monitoring\ndebug|d - enable debugging\nack|a - acknowledge a quarantined or shut down client\nsilence <duration> - suspend actions and notifications, e.g. silence 2h\nunsilence - remove the silence\n\n")
 	for {
 		select {
 		case inputStr := <-in:
-			switch inputStr {
+			fields := strings.Fields(inputStr)
+			if len(fields) == 0 {
+				continue
+			}
+			switch fields[0] {
 			case "stop", "s":
 				log.Printf("Stopping monitoring service...")
 				m.Stop()
@@ -134,6 +385,30 @@ func main() {
 			case "debug", "d":
 				log.Printf("Setting client to debug %t", !c.ReadOnly())
 				c.SetReadOnly(!c.ReadOnly(), false)
+			case "ack", "a":
+				log.Printf("Acknowledging client %s", c.IP())
+				if err := m.Acknowledge(c.IP()); err != nil {
+					log.Printf("Unable to acknowledge client: %s", err)
+				}
+			case "silence":
+				if len(fields) < 2 {
+					log.Printf("Usage: silence <duration>")
+					continue
+				}
+				duration, err := time.ParseDuration(fields[1])
+				if err != nil {
+					log.Printf("Invalid duration %s: %s", fields[1], err)
+					continue
+				}
+				log.Printf("Silencing client %s for %v", c.IP(), duration)
+				if err := m.Silence(c.IP(), duration, true, true); err != nil {
+					log.Printf("Unable to silence client: %s", err)
+				}
+			case "unsilence":
+				log.Printf("Removing silence of client %s", c.IP())
+				if err := m.Unsilence(c.IP()); err != nil {
+					log.Printf("Unable to remove silence: %s", err)
+				}
 			}
 		case <-s:
 			m.Stop()
@@ -0,0 +1,87 @@
+package miningmonitor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// MaintenanceWindow is a recurring period during which actions and/or notifications for a client are suspended,
+// statistics are still collected during the window.
+type MaintenanceWindow struct {
+	// Spec is the cron schedule of when the window starts, e.g. "0 9 * * SAT"
+	Spec                  string
+	Duration              time.Duration
+	SuppressActions       bool
+	SuppressNotifications bool
+
+	schedule cron.Schedule
+}
+
+// NewMaintenanceWindow returns a maintenance window starting on the standard cron spec and lasting for duration
+func NewMaintenanceWindow(spec string, duration time.Duration, suppressActions, suppressNotifications bool) (*MaintenanceWindow, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance window schedule %s: %s", spec, err)
+	}
+	return &MaintenanceWindow{
+		Spec:                  spec,
+		Duration:              duration,
+		SuppressActions:       suppressActions,
+		SuppressNotifications: suppressNotifications,
+		schedule:              schedule,
+	}, nil
+}
+
+// String human readable format of a maintenance window
+func (w MaintenanceWindow) String() string {
+	return fmt.Sprintf("%s for %v - [Suppress Actions? %t, Suppress Notifications? %t]",
+		w.Spec, w.Duration, w.SuppressActions, w.SuppressNotifications)
+}
+
+// active returns true if the window started within Duration of now
+func (w *MaintenanceWindow) active(now time.Time) bool {
+	return !w.schedule.Next(now.Add(-w.Duration)).After(now)
+}
+
+// silence is an ad-hoc suspension of actions and/or notifications for a client
+type silence struct {
+	mu            sync.Mutex
+	until         time.Time
+	actions       bool
+	notifications bool
+}
+
+// set the silence until the given time
+func (s *silence) set(until time.Time, actions, notifications bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.until = until
+	s.actions = actions
+	s.notifications = notifications
+}
+
+// active returns whether actions and notifications are silenced at the given time
+func (s *silence) active(now time.Time) (actions, notifications bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.After(s.until) {
+		return false, false
+	}
+	return s.actions, s.notifications
+}
+
+// suppressed returns whether actions and notifications of the client are currently suspended by a silence or
+// maintenance window
+func (cm *clientMonitoring) suppressed(now time.Time) (actions, notifications bool) {
+	actions, notifications = cm.silence.active(now)
+	for _, w := range cm.Config.MaintenanceWindows {
+		if w.active(now) {
+			actions = actions || w.SuppressActions
+			notifications = notifications || w.SuppressNotifications
+		}
+	}
+	return actions, notifications
+}
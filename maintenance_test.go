@@ -0,0 +1,35 @@
+package miningmonitor
+
+import (
+	"testing"
+	"time"
+)
+
+// fixedSchedule starts at a fixed time
+type fixedSchedule time.Time
+
+func (s fixedSchedule) Next(t time.Time) time.Time {
+	if t.Before(time.Time(s)) {
+		return time.Time(s)
+	}
+	return time.Time(s).AddDate(1, 0, 0)
+}
+
+func TestMaintenanceWindowActive(t *testing.T) {
+	start := time.Date(2018, 1, 13, 9, 0, 0, 0, time.UTC)
+	w := &MaintenanceWindow{Duration: 2 * time.Hour, schedule: fixedSchedule(start)}
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{start.Add(-time.Minute), false},
+		{start, true},
+		{start.Add(time.Hour), true},
+		{start.Add(2*time.Hour + time.Minute), false},
+	}
+	for _, tt := range tests {
+		if got := w.active(tt.at); got != tt.want {
+			t.Errorf("active at %v = %t, want %t", tt.at, got, tt.want)
+		}
+	}
+}
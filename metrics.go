@@ -0,0 +1,38 @@
+package miningmonitor
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// MetricsHandler returns a http.Handler exposing the monitor metrics in the Prometheus text format
+func (m *Monitor) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reports := m.EnergyReports()
+		var clients []string
+		for ip := range reports {
+			clients = append(clients, ip)
+		}
+		sort.Strings(clients)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintln(w, "# HELP mining_monitor_power_watts Last power reading of the client in watts")
+		fmt.Fprintln(w, "# TYPE mining_monitor_power_watts gauge")
+		for _, ip := range clients {
+			fmt.Fprintf(w, "mining_monitor_power_watts{client=%q} %f\n", ip, reports[ip].Power)
+		}
+		fmt.Fprintln(w, "# HELP mining_monitor_energy_kwh Energy used by the client in kWh for the current period")
+		fmt.Fprintln(w, "# TYPE mining_monitor_energy_kwh gauge")
+		for _, ip := range clients {
+			fmt.Fprintf(w, "mining_monitor_energy_kwh{client=%q,period=\"day\"} %f\n", ip, reports[ip].DayKWh)
+			fmt.Fprintf(w, "mining_monitor_energy_kwh{client=%q,period=\"month\"} %f\n", ip, reports[ip].MonthKWh)
+		}
+		fmt.Fprintln(w, "# HELP mining_monitor_energy_cost Cost of the energy used by the client for the current period")
+		fmt.Fprintln(w, "# TYPE mining_monitor_energy_cost gauge")
+		for _, ip := range clients {
+			fmt.Fprintf(w, "mining_monitor_energy_cost{client=%q,period=\"day\"} %f\n", ip, reports[ip].DayCost)
+			fmt.Fprintf(w, "mining_monitor_energy_cost{client=%q,period=\"month\"} %f\n", ip, reports[ip].MonthCost)
+		}
+	})
+}
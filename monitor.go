@@ -1,7 +1,12 @@
 package miningmonitor
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
@@ -9,55 +14,178 @@ import (
 
 // ClientMonitorConfig used to configure each client
 type ClientMonitorConfig struct {
-	Thresholds                  []*Threshold
-	CheckFailsBeforeReboot      int
-	RebootFailsBeforePowerCycle int
-	RebootInterval              time.Duration
-	StatsInterval               time.Duration
-	StateInterval               time.Duration
-	PowerCycleOnly              bool
+	Thresholds []*Threshold
+	// Escalation is the ordered list of actions taken while the client keeps failing checks
+	Escalation    []EscalationStep
+	StatsInterval time.Duration
+	StateInterval time.Duration
+	// CircuitGroup name the client is powered from, used to coordinate power cycling with other clients
+	CircuitGroup string
+	// Priority of the client within its circuit group, the lowest priority client is powered off first when over budget
+	Priority int
+	// Timeout of each request made to the client, 0 for no timeout other than the client's own
+	Timeout time.Duration
+	// DisableFailingGPUs disables GPUs exceeding a per GPU threshold instead of rebooting, if the client is a GPUController.
+	// GPUs are disabled once the checks fail as often as the escalation step requires. A disabled GPU lowers the rig
+	// hash rate, rig wide thresholds such as the baseline hash rate threshold do not know about disabled GPUs and
+	// should not be combined with it. GPUs failing only alt algorithm thresholds are switched to the main algorithm only.
+	DisableFailingGPUs bool
+	// MaxDisabledGPUs is the number of GPUs that may be disabled before rebooting instead, <= 0 for no limit
+	MaxDisabledGPUs int
+	// VerifyTimeout is how long to wait for the client to recover after an action before it counts as failed,
+	// 0 to trust the action without verifying
+	VerifyTimeout time.Duration
+	// QuarantineAfter is the number of failed recoveries within QuarantineWindow before the client is quarantined
+	// and no further actions are taken until it is acknowledged, 0 to never quarantine
+	QuarantineAfter int
+	// QuarantineWindow failed recoveries are counted over, 0 to count all failed recoveries
+	QuarantineWindow time.Duration
+	// MaintenanceWindows during which actions and/or notifications are suspended
+	MaintenanceWindows []*MaintenanceWindow
+	// UnreachableFailsBeforePowerCycle is the number of consecutive failures to get statistics before the client
+	// is power cycled directly, skipping actions that need the unreachable client to respond. 0 to disable
+	UnreachableFailsBeforePowerCycle int
+	// UnreachableCooldown after power cycling an unreachable client before it is power cycled again
+	UnreachableCooldown time.Duration
+	// ResetAfter is how long checks must stay clean before failed checks and escalation are reset, 0 to reset
+	// after a single clean check
+	ResetAfter time.Duration
+	// ShutdownCooldown is how long a client shut down by a threshold stays powered off before it is powered on
+	// again, 0 to keep it off until it is acknowledged
+	ShutdownCooldown time.Duration
+	// PreventiveRestart restarts or reboots the client once it has been running for too long, nil to disable
+	PreventiveRestart *PreventiveRestart
 }
 
 // NewClientMonitorConfig returns a new basic client monitor config
-func NewClientMonitorConfig(thresholds []*Threshold, checkFailsBeforeReboot, rebootFailsBeforePowerCycle int,
-	rebootInterval, statsInterval, stateInterval time.Duration, powerCycleOnly bool) *ClientMonitorConfig {
+func NewClientMonitorConfig(thresholds []*Threshold, escalation []EscalationStep,
+	statsInterval, stateInterval time.Duration) *ClientMonitorConfig {
 	return &ClientMonitorConfig{
-		Thresholds:                  thresholds,
-		CheckFailsBeforeReboot:      checkFailsBeforeReboot,
-		RebootFailsBeforePowerCycle: rebootFailsBeforePowerCycle,
-		RebootInterval:              rebootInterval,
-		StatsInterval:               statsInterval,
-		StateInterval:               stateInterval,
-		PowerCycleOnly:              powerCycleOnly,
+		Thresholds:    thresholds,
+		Escalation:    escalation,
+		StatsInterval: statsInterval,
+		StateInterval: stateInterval,
 	}
 }
 
 type clientMonitoring struct {
 	C      Client
 	Config *ClientMonitorConfig
+
+	energy  *energyMeter
+	ack     chan bool
+	silence *silence
+	// unreachable is 1 while the client is failing to respond, accessed atomically
+	unreachable int32
 }
 
 // Monitor is used to monitor multiple clients
 type Monitor struct {
-	c            []clientMonitoring
+	c            []*clientMonitoring
+	circuits     map[string]*CircuitGroup
+	tariff       Tariff
+	guard        *NetworkGuard
 	EventService *EventService
 
-	stop     chan bool
-	interval time.Duration
-	state    int
+	preventiveMu   sync.Mutex
+	preventiveGap  time.Duration
+	lastPreventive time.Time
+
+	stop                  chan bool
+	cancel                context.CancelFunc
+	workers               int
+	interval              time.Duration
+	energySummaryInterval time.Duration
+	state                 int
 }
 
 // NewMonitor returns a new monitoring service for multiple clients.
 func NewMonitor(eventService *EventService) *Monitor {
 	return &Monitor{
-		c:            []clientMonitoring{},
+		c:            []*clientMonitoring{},
+		circuits:     map[string]*CircuitGroup{},
 		EventService: eventService,
 	}
 }
 
+// AddCircuitGroup to coordinate power cycling of clients configured with the same CircuitGroup name
+func (m *Monitor) AddCircuitGroup(g *CircuitGroup) {
+	m.circuits[g.Name] = g
+}
+
 // AddClient to be monitored along with its corresponding configuration
 func (m *Monitor) AddClient(c Client, config *ClientMonitorConfig) {
-	m.c = append(m.c, clientMonitoring{C: c, Config: config})
+	m.c = append(m.c, &clientMonitoring{C: c, Config: config, energy: &energyMeter{}, ack: make(chan bool, 1), silence: &silence{}})
+}
+
+// client returns the monitoring of the client with the given IP
+func (m *Monitor) client(ip string) (*clientMonitoring, error) {
+	for _, c := range m.c {
+		if c.C.IP() == ip {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("no client with IP %s is being monitored", ip)
+}
+
+// Silence actions and/or notifications for the client with the given IP for a duration, statistics are still collected
+func (m *Monitor) Silence(ip string, duration time.Duration, actions, notifications bool) error {
+	c, err := m.client(ip)
+	if err != nil {
+		return err
+	}
+	c.silence.set(time.Now().Add(duration), actions, notifications)
+	m.EventService.E <- NewLogEvent(c.C, fmt.Sprintf("silenced for %v [Actions? %t, Notifications? %t]", duration, actions, notifications))
+	return nil
+}
+
+// Unsilence the client with the given IP
+func (m *Monitor) Unsilence(ip string) error {
+	c, err := m.client(ip)
+	if err != nil {
+		return err
+	}
+	c.silence.set(time.Time{}, false, false)
+	m.EventService.E <- NewLogEvent(c.C, "silence removed")
+	return nil
+}
+
+// Acknowledge a quarantined client so that the monitor resumes taking actions on it, a client which was shut
+// down is powered back on
+func (m *Monitor) Acknowledge(ip string) error {
+	c, err := m.client(ip)
+	if err != nil {
+		return err
+	}
+	select {
+	case c.ack <- true:
+	default:
+	}
+	return nil
+}
+
+// SetNetworkGuard checked before taking any action on a client
+func (m *Monitor) SetNetworkGuard(g *NetworkGuard) {
+	m.guard = g
+}
+
+// SetTariff used to calculate the cost of the energy used by the clients
+func (m *Monitor) SetTariff(t Tariff) {
+	m.tariff = t
+}
+
+// SetEnergySummaryInterval to periodically send a summary of the energy used by each client, 0 to disable
+func (m *Monitor) SetEnergySummaryInterval(interval time.Duration) {
+	m.energySummaryInterval = interval
+}
+
+// EnergyReports returns the energy used by each client keyed by the client IP
+func (m *Monitor) EnergyReports() map[string]EnergyReport {
+	reports := map[string]EnergyReport{}
+	for _, c := range m.c {
+		reports[c.C.IP()] = c.energy.Report()
+	}
+	return reports
 }
 
 // Start the monitoring service
@@ -65,11 +193,20 @@ func (m *Monitor) Start() error {
 	if m.state == RUNNING {
 		return fmt.Errorf("monitor already running")
 	}
-	m.stop = make(chan bool, len(m.c))
+	m.workers = len(m.c)
+	if m.energySummaryInterval > 0 {
+		m.workers++
+	}
+	m.stop = make(chan bool, m.workers)
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
 	m.state = RUNNING
 	for _, c := range m.c {
 		m.EventService.E <- NewLogEvent(c.C, "starting monitoring...")
-		go m.monitorClient(m.stop, c.C, c.Config)
+		go m.monitorClient(ctx, m.stop, c)
+	}
+	if m.energySummaryInterval > 0 {
+		go m.energySummary(m.stop)
 	}
 	go m.EventService.Start()
 	return nil
@@ -80,7 +217,9 @@ func (m *Monitor) Stop() error {
 	if m.state == STOPPED {
 		return fmt.Errorf("monitor already stopped")
 	}
-	for i := 0; i < len(m.c); i++ {
+	// Cancel any requests in flight so the clients see the stop
+	m.cancel()
+	for i := 0; i < m.workers; i++ {
 		m.stop <- true
 	}
 	m.EventService.Stop()
@@ -89,56 +228,136 @@ func (m *Monitor) Stop() error {
 	return nil
 }
 
-func (m *Monitor) monitorClient(stop chan bool, c Client, config *ClientMonitorConfig) {
+func (m *Monitor) monitorClient(ctx context.Context, stop chan bool, cm *clientMonitoring) {
+	c := cm.C
+	config := cm.Config
 	m.EventService.E <- NewLogEvent(c,
-		fmt.Sprintf("Monitor Starting on %s\nPower Cycle Only: %t\nThresholds: %s\nPowerCycle: %t\nReadOnly: %t\nCheckFailsBeforeReboot: %d\nRebootFailsBeforePowercycle: %d\nRebootInterval: %v\nStatsInterval: %v\nStateInterval: %v",
-			c.IP(), config.PowerCycleOnly, config.Thresholds, c.PowerCycleEnabled(), c.ReadOnly(), config.CheckFailsBeforeReboot, config.RebootFailsBeforePowerCycle, config.RebootInterval, config.StatsInterval, config.StateInterval),
+		fmt.Sprintf("Monitor Starting on %s\nThresholds: %s\nEscalation: %v\nMaintenanceWindows: %v\nPowerCycle: %t\nReadOnly: %t\nStatsInterval: %v\nStateInterval: %v",
+			c.IP(), config.Thresholds, config.Escalation, config.MaintenanceWindows, c.PowerCycleEnabled(), c.ReadOnly(), config.StatsInterval, config.StateInterval),
 	)
+	if config.PreventiveRestart != nil {
+		m.EventService.E <- NewLogEvent(c, fmt.Sprintf("PreventiveRestart: %s", config.PreventiveRestart))
+	}
 	stateTicker := time.NewTicker(config.StateInterval)
 	statsTicker := time.NewTicker(config.StatsInterval)
 
-	failedReboots := 0
+	esc := &escalation{steps: config.Escalation}
 	failedChecks := 0
-	lastReboot := time.Now().Add(-config.RebootInterval)
 	var errors []error
 	reset := false
+	gaveUp := false
 	state := RUNNING
+	var action Action
+	// Running time of the client before the action was taken, used to verify it restarted
+	runningTime := 0
+	var verifyUntil time.Time
+	// Times of actions that failed to recover the client, used to quarantine it
+	var failedRecoveries []time.Time
+	// Consecutive failures to get statistics from the client
+	unreachable := 0
+	unreachableCycle := false
+	// Set when the action is a preventive restart of a healthy client rather than recovering it
+	preventive := false
+	var unreachableUntil time.Time
+	// Time checks have been clean since, used to reset once they stay clean
+	var cleanSince time.Time
+	// Algorithm disabled on each GPU due to exceeding thresholds, the main algorithm when the whole GPU is disabled.
+	// The miner enables all GPUs again once restarted.
+	disabledGPUs := map[int]Algorithm{}
+	// Time a client shut down by a threshold is powered on again
+	var shutdownUntil time.Time
 
 	for {
 		select {
 		case <-stateTicker.C:
-			glog.V(1).Infof("State: {failedReboots: %d, failedChecks: %d}", failedReboots, failedChecks)
+			glog.V(1).Infof("State: {step: %d, attempts: %d, failedChecks: %d}", esc.step, esc.attempts, failedChecks)
+			if state == VERIFYING || state == QUARANTINED || state == SHUTDOWN {
+				continue
+			}
 			if reset {
-				failedReboots = 0
 				failedChecks = 0
 				errors = []error{}
+				esc.reset()
+				gaveUp = false
 				reset = false
 			}
-			// If client has power cycling enabled and number of failed reboots is greater than threshold OR power cycle only enabled and failed checks greater than threshold and last reboot is longer than threshold
-			if c.PowerCycleEnabled() && (failedReboots >= config.RebootFailsBeforePowerCycle || config.PowerCycleOnly && failedChecks >= config.CheckFailsBeforeReboot && time.Now().Sub(lastReboot) > config.RebootInterval) {
-				if state != POWERCYCLING {
-					m.EventService.E <- NewLogEvent(c, "transitioning to POWERCYCLING state...")
-				}
-				state = POWERCYCLING
-			} else if !config.PowerCycleOnly && failedChecks >= config.CheckFailsBeforeReboot && time.Now().Sub(lastReboot) > config.RebootInterval {
-				if state != REBOOTING {
-					m.EventService.E <- NewLogEvent(c, "transitioning to REBOOTING state...")
-				}
-				state = REBOOTING
-			} else {
-				if state != RUNNING {
-					m.EventService.E <- NewLogEvent(c, "transitioning to RUNNING state...")
-				}
-				state = RUNNING
+			if config.QuarantineWindow > 0 {
+				failedRecoveries = since(failedRecoveries, time.Now().Add(-config.QuarantineWindow))
+			}
+			if config.QuarantineAfter > 0 && (len(failedRecoveries) >= config.QuarantineAfter || esc.gaveUp(c, failedChecks)) {
+				m.EventService.E <- NewLogEvent(c, "transitioning to QUARANTINED state...")
+				m.notify(c, "CRITICAL: Client QUARANTINED",
+					fmt.Sprintf("Client failed to recover %d times, no further actions will be taken until it is acknowledged. Errors: %s",
+						len(failedRecoveries), fmtErrors(errors)))
+				state = QUARANTINED
+				continue
+			}
+			if !gaveUp && esc.gaveUp(c, failedChecks) {
+				gaveUp = true
+				m.notify(c, "GAVE UP Recovering",
+					fmt.Sprintf("Every escalation step was taken without the client recovering, no further actions will be taken until it is healthy again. Errors: %s", fmtErrors(errors)))
+			}
+			newState := RUNNING
+			unreachableCycle = false
+			// A preventive restart already took its turn among the clients, keep it until it is taken
+			pendingPreventive := preventive && state != RUNNING
+			preventive = false
+			if suppressActions, _ := cm.suppressed(time.Now()); suppressActions {
+				glog.V(1).Infof("[%s] actions suppressed", c.IP())
+			} else if config.UnreachableFailsBeforePowerCycle > 0 && unreachable >= config.UnreachableFailsBeforePowerCycle &&
+				c.PowerCycleEnabled() && !time.Now().Before(unreachableUntil) {
+				action = PowerCycleAction
+				newState = POWERCYCLING
+				unreachableCycle = true
+			} else if a, ok := esc.ready(c, failedChecks); ok {
+				action = a
+				newState = a.state()
+			} else if pendingPreventive || failedChecks == 0 && unreachable == 0 && m.preventiveRestartDue(cm, runningTime) {
+				action = config.PreventiveRestart.Action
+				newState = action.state()
+				preventive = true
+			}
+			if newState != state {
+				m.EventService.E <- NewLogEvent(c, fmt.Sprintf("transitioning to %s state...", stateName(newState)))
 			}
+			state = newState
 		case <-statsTicker.C:
+			// A shut down client stays off until it has cooled down, even if its circuit group has room for it
+			if state != SHUTDOWN && m.shedClient(ctx, c, config) {
+				continue
+			}
 			switch state {
 			case RUNNING:
-				stats, err := c.Stats()
-				if err != nil {
+				reqCtx, cancel := requestContext(ctx, config)
+				stats, err := c.Stats(reqCtx)
+				cancel()
+				if err != nil && isConnectionError(err) {
+					m.EventService.E <- NewErrorEvent(c, err)
+					atomic.StoreInt32(&cm.unreachable, 1)
+					unreachable++
+					if unreachable == config.UnreachableFailsBeforePowerCycle {
+						m.notify(c, "Client Unreachable", fmt.Sprintf("Client failed to respond %d times in a row: %s", unreachable, err))
+					}
+				} else if err != nil {
+					// The client replied, it is reachable even though its statistics could not be read
 					m.EventService.E <- NewErrorEvent(c, err)
+					atomic.StoreInt32(&cm.unreachable, 0)
+					unreachable = 0
 				} else {
+					atomic.StoreInt32(&cm.unreachable, 0)
+					unreachable = 0
+					// The miner enables all GPUs again when it restarts on its own
+					if stats.RunningTime < runningTime {
+						disabledGPUs = map[int]Algorithm{}
+					}
+					runningTime = stats.RunningTime
+					m.readPowerState(ctx, c, config, stats)
+					m.checkCircuitBudget(ctx, c, config, stats)
+					if stats.PowerState != nil {
+						cm.energy.record(time.Now(), stats.PowerState.Power, m.tariff)
+					}
 					var rebootErrors []error
+					var shutdownErrors []error
 					var emailErrors []error
 					for _, t := range config.Thresholds {
 						thresholdErrors := t.Check(stats)
@@ -146,11 +365,49 @@ func (m *Monitor) monitorClient(stop chan bool, c Client, config *ClientMonitorC
 							if t.SendEmail {
 								emailErrors = append(emailErrors, thresholdErrors...)
 							}
-							if t.CauseReboot {
+							if t.CauseShutdown {
+								shutdownErrors = append(shutdownErrors, thresholdErrors...)
+							} else if t.CauseReboot {
 								rebootErrors = append(rebootErrors, thresholdErrors...)
 							}
 						}
 					}
+					rebootErrors = filterDisabledGPUs(rebootErrors, disabledGPUs)
+					emailErrors = filterDisabledGPUs(emailErrors, disabledGPUs)
+					// Decide whether the check was clean before suppression drops its errors, a failing client must
+					// not reset its escalation while silenced
+					clean := len(rebootErrors) == 0 && len(shutdownErrors) == 0 && len(emailErrors) == 0
+					// Don't build up failed checks while actions are suppressed, the client is likely being worked on
+					suppressActions, _ := cm.suppressed(time.Now())
+					// An overheating client is shut down even while actions or notifications are suppressed
+					if len(shutdownErrors) > 0 {
+						if err := m.shutdown(ctx, c, config, shutdownErrors); err != nil {
+							m.EventService.E <- NewErrorEvent(c, err)
+							m.EventService.E <- NewEmailEvent(c, "CRITICAL: FAILED to Shut Down",
+								fmt.Sprintf("Client could not be shut down due to error: %s, errors: %s", err, fmtErrors(shutdownErrors)))
+							// Fall back to the escalation policy rather than leaving the client overheating
+							rebootErrors = append(rebootErrors, shutdownErrors...)
+						} else {
+							failedChecks = 0
+							cleanSince = time.Time{}
+							shutdownUntil = time.Now().Add(config.ShutdownCooldown)
+							state = SHUTDOWN
+							continue
+						}
+					}
+					if len(rebootErrors) > 0 && suppressActions {
+						for _, err := range rebootErrors {
+							m.EventService.E <- NewErrorEvent(c, err)
+						}
+						rebootErrors = nil
+					}
+					// Only disable GPUs once the escalation would act on the failed checks, not on a momentary dip
+					if _, due := esc.ready(c, failedChecks+1); len(rebootErrors) > 0 && config.DisableFailingGPUs && due {
+						if m.disableGPUs(ctx, c, config, stats, disabledGPUs, rebootErrors) {
+							failedChecks = 0
+							rebootErrors = nil
+						}
+					}
 					if len(rebootErrors) > 0 {
 						for _, err := range rebootErrors {
 							m.EventService.E <- NewErrorEvent(c, err)
@@ -164,39 +421,458 @@ func (m *Monitor) monitorClient(stop chan bool, c Client, config *ClientMonitorC
 							m.EventService.E <- NewErrorEvent(c, err)
 							body += err.Error() + "\n\r"
 						}
-						m.EventService.E <- NewEmailEvent(c, "Thresholds Exceeded!", body)
+						m.notify(c, "Thresholds Exceeded!", body)
 					}
-					if len(rebootErrors) == 0 && len(emailErrors) == 0 {
-						reset = true
+					if clean {
+						if cleanSince.IsZero() {
+							cleanSince = time.Now()
+						}
+						if time.Since(cleanSince) >= config.ResetAfter {
+							reset = true
+						}
+					} else {
+						cleanSince = time.Time{}
 					}
 				}
-			case REBOOTING:
-				m.EventService.E <- NewLogEvent(c, "Attempting to reboot client...")
-				if err := c.Reboot(); err != nil {
-					m.EventService.E <- NewErrorEvent(c, fmt.Errorf("failed to reboot: %s", err))
-					m.EventService.E <- NewEmailEvent(c, "FAILED to Reboot", fmt.Sprintf("Client was unable to be restarted due to error: %s", err))
-					failedReboots++
-				} else {
-					m.EventService.E <- NewLogEvent(c, "rebooted successfully")
-					m.EventService.E <- NewEmailEvent(c, "SUCCESSFULLY rebooted", fmt.Sprintf("Client was restarted due to events: %s", fmtErrors(errors)))
-					reset = true
-					lastReboot = time.Now()
+			default:
+				if err := m.checkNetworkGuard(ctx); err != nil {
+					m.EventService.E <- NewLogEvent(c, fmt.Sprintf("not attempting to %s client: %s", strings.ToLower(action.String()), err))
+					continue
 				}
-			case POWERCYCLING:
-				m.EventService.E <- NewLogEvent(c, fmt.Sprintf("Attempting to power cycle..."))
-				if err := c.PowerCycle(); err != nil {
-					m.EventService.E <- NewErrorEvent(c, err)
-					m.EventService.E <- NewEmailEvent(c, "FAILED to Power Cycle", fmt.Sprintf("Client was unable to power cycle due to error: %s", err))
+				m.EventService.E <- NewLogEvent(c, fmt.Sprintf("Attempting to %s client...", strings.ToLower(action.String())))
+				err := m.takeAction(ctx, c, config, action)
+				if unreachableCycle {
+					unreachable = 0
+					unreachableUntil = time.Now().Add(config.UnreachableCooldown)
+				} else if !preventive {
+					esc.taken(c)
+				}
+				failedChecks = 0
+				state = RUNNING
+				if err != nil {
+					m.EventService.E <- NewErrorEvent(c, fmt.Errorf("failed to %s: %s", strings.ToLower(action.String()), err))
+					m.notify(c, fmt.Sprintf("FAILED to %s", action),
+						fmt.Sprintf("Client was unable to %s due to error: %s", strings.ToLower(action.String()), err))
+					failedRecoveries = append(failedRecoveries, time.Now())
+				} else if config.VerifyTimeout > 0 {
+					m.EventService.E <- NewLogEvent(c, fmt.Sprintf("%s, transitioning to VERIFYING state...", action.past()))
+					disabledGPUs = map[int]Algorithm{}
+					verifyUntil = time.Now().Add(config.VerifyTimeout)
+					state = VERIFYING
+				} else if preventive {
+					m.EventService.E <- NewLogEvent(c, fmt.Sprintf("%s successfully", action.past()))
+					m.notify(c, fmt.Sprintf("SUCCESSFULLY %s", action.past()),
+						fmt.Sprintf("Client was %s as a preventive restart after running for %d minutes", action.past(), runningTime))
+					disabledGPUs = map[int]Algorithm{}
+					// Don't restart again before the next statistics show the new running time
+					runningTime = 0
 				} else {
-					m.EventService.E <- NewLogEvent(c, "power cycled successfully")
-					m.EventService.E <- NewEmailEvent(c, "SUCCESSFULLY Power Cycled", fmt.Sprintf("Client was power cycled due to errors: %s", fmtErrors(errors)))
+					m.EventService.E <- NewLogEvent(c, fmt.Sprintf("%s successfully", action.past()))
+					m.notify(c, fmt.Sprintf("SUCCESSFULLY %s", action.past()),
+						fmt.Sprintf("Client was %s due to errors: %s", action.past(), fmtErrors(errors)))
+					disabledGPUs = map[int]Algorithm{}
+				}
+			case VERIFYING:
+				if newRunningTime, ok := m.verify(ctx, c, config, runningTime); ok {
+					m.EventService.E <- NewLogEvent(c, fmt.Sprintf("%s successfully and recovered", action.past()))
+					if preventive {
+						m.notify(c, fmt.Sprintf("SUCCESSFULLY %s", action.past()),
+							fmt.Sprintf("Client was %s as a preventive restart after running for %d minutes", action.past(), runningTime))
+					} else {
+						m.notify(c, fmt.Sprintf("SUCCESSFULLY %s", action.past()),
+							fmt.Sprintf("Client was %s and recovered from errors: %s", action.past(), fmtErrors(errors)))
+					}
+					// The running time from before the action would make a preventive restart due again
+					runningTime = newRunningTime
 					reset = true
-					lastReboot = time.Now()
+					state = RUNNING
+				} else if time.Now().After(verifyUntil) {
+					m.EventService.E <- NewErrorEvent(c, fmt.Errorf("client did not recover within %v of %s", config.VerifyTimeout, action.past()))
+					m.notify(c, "FAILED to Recover",
+						fmt.Sprintf("Client was %s but did not recover within %v, errors: %s", action.past(), config.VerifyTimeout, fmtErrors(errors)))
+					// An unreachable client is power cycled again once it stays unreachable, don't escalate over its API
+					if !unreachableCycle && !preventive {
+						esc.actionFailed()
+					}
+					// Don't restart again before the next statistics show the new running time
+					if preventive {
+						runningTime = 0
+					}
+					failedRecoveries = append(failedRecoveries, time.Now())
+					state = RUNNING
+				}
+			case QUARANTINED:
+				glog.V(1).Infof("[%s] quarantined, waiting to be acknowledged", c.IP())
+			case SHUTDOWN:
+				if config.ShutdownCooldown <= 0 || time.Now().Before(shutdownUntil) {
+					glog.V(1).Infof("[%s] shut down, waiting to cool down", c.IP())
+					continue
+				}
+				if err := m.powerOn(ctx, c, config); err != nil {
+					m.EventService.E <- NewErrorEvent(c, fmt.Errorf("failed to power on: %s", err))
+					continue
+				}
+				disabledGPUs = map[int]Algorithm{}
+				state = RUNNING
+				m.EventService.E <- NewLogEvent(c, "cooled down, transitioning to RUNNING state...")
+				m.notify(c, "Powered On", fmt.Sprintf("Client was powered back on after cooling down for %v", config.ShutdownCooldown))
+			}
+		case <-cm.ack:
+			if state == SHUTDOWN {
+				if err := m.powerOn(ctx, c, config); err != nil {
+					m.EventService.E <- NewErrorEvent(c, fmt.Errorf("failed to power on: %s", err))
+					continue
 				}
+				disabledGPUs = map[int]Algorithm{}
+				state = RUNNING
+				m.EventService.E <- NewLogEvent(c, "acknowledged, transitioning to RUNNING state...")
+				m.notify(c, "Powered On", "Client was acknowledged and powered back on")
+				continue
+			}
+			if state != QUARANTINED {
+				continue
 			}
+			failedChecks = 0
+			errors = []error{}
+			esc.reset()
+			gaveUp = false
+			failedRecoveries = nil
+			state = RUNNING
+			m.EventService.E <- NewLogEvent(c, "acknowledged, transitioning to RUNNING state...")
+			m.notify(c, "Client Acknowledged", "Client was acknowledged and monitoring actions have resumed")
 		case <-stop:
 			m.EventService.E <- NewLogEvent(c, "Client monitoring stopped")
 			return
 		}
 	}
 }
+
+// isConnectionError returns true if the client failed to connect, write to or read from the remote host
+func isConnectionError(err error) bool {
+	var connErr *ConnectionError
+	return errors.As(err, &connErr)
+}
+
+// readPowerState of the client into its statistics if it has a power service, failures of the power service are
+// reported without affecting the statistics of the client
+func (m *Monitor) readPowerState(ctx context.Context, c Client, config *ClientMonitorConfig, stats *Statistics) {
+	pm, ok := c.(PowerMeter)
+	if !ok || !c.PowerCycleEnabled() {
+		return
+	}
+	reqCtx, cancel := requestContext(ctx, config)
+	defer cancel()
+	state, err := pm.PowerState(reqCtx)
+	if err != nil {
+		m.EventService.E <- NewErrorEvent(c, fmt.Errorf("failed to read power state: %s", err))
+		return
+	}
+	stats.PowerState = state
+}
+
+// verify returns the new running time of the client and true once the client responds again with its running
+// time reset and no thresholds causing actions exceeded
+func (m *Monitor) verify(ctx context.Context, c Client, config *ClientMonitorConfig, runningTime int) (int, bool) {
+	reqCtx, cancel := requestContext(ctx, config)
+	stats, err := c.Stats(reqCtx)
+	cancel()
+	if err != nil {
+		glog.V(1).Infof("[%s] waiting for client to respond: %s", c.IP(), err)
+		return 0, false
+	}
+	if runningTime > 0 && stats.RunningTime >= runningTime {
+		glog.V(1).Infof("[%s] waiting for running time %d to reset", c.IP(), stats.RunningTime)
+		return 0, false
+	}
+	m.readPowerState(ctx, c, config, stats)
+	for _, t := range config.Thresholds {
+		if t.CauseReboot && len(t.Check(stats)) > 0 {
+			glog.V(1).Infof("[%s] waiting for %s threshold to recover", c.IP(), t.Name)
+			return 0, false
+		}
+	}
+	return stats.RunningTime, true
+}
+
+// notify sends an email about the client unless its notifications are suppressed
+func (m *Monitor) notify(c Client, subject, body string) {
+	for _, cm := range m.c {
+		if cm.C != c {
+			continue
+		}
+		if _, suppressNotifications := cm.suppressed(time.Now()); suppressNotifications {
+			m.EventService.E <- NewLogEvent(c, fmt.Sprintf("notifications suppressed, not sending %s", subject))
+			return
+		}
+	}
+	m.EventService.E <- NewEmailEvent(c, subject, body)
+}
+
+// since returns the times after the given time
+func since(times []time.Time, after time.Time) []time.Time {
+	var res []time.Time
+	for _, t := range times {
+		if t.After(after) {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// filterDisabledGPUs removes the errors of GPUs that have already been disabled, and the alt algorithm errors of
+// GPUs that have already been switched to the main algorithm only
+func filterDisabledGPUs(errors []error, disabled map[int]Algorithm) []error {
+	var filtered []error
+	for _, err := range errors {
+		if gpuErr, ok := err.(*GPUThresholdError); ok {
+			if algorithm, ok := disabled[gpuErr.GPU]; ok && (algorithm == MainAlgorithm || gpuErr.Algorithm == algorithm) {
+				continue
+			}
+		}
+		filtered = append(filtered, err)
+	}
+	return filtered
+}
+
+// disableGPUs disables the GPUs exceeding thresholds rather than rebooting the whole client. GPUs failing only the
+// alt algorithm are switched to the main algorithm only and keep mining. It returns false if any error is not for a
+// single GPU, if too many GPUs would be disabled or if the GPUs could not be disabled.
+func (m *Monitor) disableGPUs(ctx context.Context, c Client, config *ClientMonitorConfig, stats *Statistics,
+	disabled map[int]Algorithm, errors []error) bool {
+	gc, ok := c.(GPUController)
+	if !ok {
+		return false
+	}
+	// The algorithm to disable on each GPU, a main algorithm error disables the whole GPU
+	gpus := map[int]Algorithm{}
+	for _, err := range errors {
+		gpuErr, ok := err.(*GPUThresholdError)
+		if !ok {
+			return false
+		}
+		if algorithm, ok := gpus[gpuErr.GPU]; !ok || algorithm != MainAlgorithm {
+			gpus[gpuErr.GPU] = gpuErr.Algorithm
+		}
+	}
+	total := 0
+	for gpu, algorithm := range disabled {
+		if _, ok := gpus[gpu]; !ok && algorithm == MainAlgorithm {
+			total++
+		}
+	}
+	for _, algorithm := range gpus {
+		if algorithm == MainAlgorithm {
+			total++
+		}
+	}
+	// Keep at least one GPU running, if all are failing something is wrong with the whole rig
+	if total >= len(stats.MainGpuHashRate) || config.MaxDisabledGPUs > 0 && total > config.MaxDisabledGPUs {
+		return false
+	}
+	for gpu, algorithm := range gpus {
+		reqCtx, cancel := requestContext(ctx, config)
+		var err error
+		if algorithm == MainAlgorithm {
+			m.EventService.E <- NewLogEvent(c, fmt.Sprintf("Attempting to disable GPU %d...", gpu))
+			err = gc.SetGPUEnabled(reqCtx, gpu, false)
+		} else {
+			m.EventService.E <- NewLogEvent(c, fmt.Sprintf("Attempting to switch GPU %d to the main algorithm only...", gpu))
+			err = gc.SetGPUMainOnly(reqCtx, gpu)
+		}
+		cancel()
+		if err != nil {
+			m.EventService.E <- NewErrorEvent(c, fmt.Errorf("failed to disable %s algorithm on GPU %d: %s", algorithm, gpu, err))
+			return false
+		}
+		disabled[gpu] = algorithm
+	}
+	m.notify(c, "Disabled GPUs", fmt.Sprintf("GPUs were disabled due to errors: %s", fmtErrors(errors)))
+	return true
+}
+
+// energySummary periodically sends the energy used by each client
+func (m *Monitor) energySummary(stop chan bool) {
+	ticker := time.NewTicker(m.energySummaryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, c := range m.c {
+				m.notify(c.C, "Energy Summary", c.energy.Report().String())
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// checkNetworkGuard returns an error if actions should be suppressed due to a likely network outage
+func (m *Monitor) checkNetworkGuard(ctx context.Context) error {
+	if m.guard == nil {
+		return nil
+	}
+	unreachable := 0
+	for _, c := range m.c {
+		if atomic.LoadInt32(&c.unreachable) == 1 {
+			unreachable++
+		}
+	}
+	return m.guard.check(ctx, unreachable, len(m.c))
+}
+
+// takeAction on the client to recover it
+func (m *Monitor) takeAction(ctx context.Context, c Client, config *ClientMonitorConfig, action Action) error {
+	if action == PowerCycleAction {
+		return m.powerCycle(ctx, c, config)
+	}
+	reqCtx, cancel := requestContext(ctx, config)
+	defer cancel()
+	if action == RestartAction {
+		return c.Restart(reqCtx)
+	}
+	return c.Reboot(reqCtx)
+}
+
+// requestContext returns a context bounding a single request to the client by the configured timeout
+func requestContext(ctx context.Context, config *ClientMonitorConfig) (context.Context, context.CancelFunc) {
+	if config.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, config.Timeout)
+}
+
+// powerCycle the client, waiting for its circuit group to allow it to power on
+func (m *Monitor) powerCycle(ctx context.Context, c Client, config *ClientMonitorConfig) error {
+	g, ok := m.circuits[config.CircuitGroup]
+	if !ok {
+		reqCtx, cancel := requestContext(ctx, config)
+		defer cancel()
+		return c.PowerCycle(reqCtx)
+	}
+	if err := g.checkPowerOn(c); err != nil {
+		return err
+	}
+	m.EventService.E <- NewLogEvent(c, fmt.Sprintf("waiting for circuit group %s to allow power on...", g.Name))
+	if err := g.acquirePowerOn(ctx); err != nil {
+		return err
+	}
+	reqCtx, cancel := requestContext(ctx, config)
+	defer cancel()
+	return g.runPowerOn(reqCtx, c.PowerCycle)
+}
+
+// shutdown powers the client off to let it cool down
+func (m *Monitor) shutdown(ctx context.Context, c Client, config *ClientMonitorConfig, errors []error) error {
+	pc, ok := c.(PowerController)
+	if !ok || !c.PowerCycleEnabled() {
+		return fmt.Errorf("client has no power service to shut it down")
+	}
+	m.EventService.E <- NewLogEvent(c, "Attempting to shut down client...")
+	reqCtx, cancel := requestContext(ctx, config)
+	defer cancel()
+	if err := pc.PowerOff(reqCtx); err != nil {
+		return fmt.Errorf("failed to power off: %s", err)
+	}
+	// The client no longer draws power from its circuit group and must not be powered on by it
+	if g, ok := m.circuits[config.CircuitGroup]; ok {
+		g.setShutdown(c, true)
+	}
+	until := "it is acknowledged"
+	if config.ShutdownCooldown > 0 {
+		until = fmt.Sprintf("it has cooled down for %v or is acknowledged", config.ShutdownCooldown)
+	}
+	m.EventService.E <- NewLogEvent(c, "shut down, transitioning to SHUTDOWN state...")
+	m.EventService.E <- NewEmailEvent(c, "CRITICAL: Client SHUT DOWN",
+		fmt.Sprintf("Client was powered off and will stay off until %s. Errors: %s", until, fmtErrors(errors)))
+	return nil
+}
+
+// powerOn a client which was shut down, waiting for its circuit group to allow it to power on
+func (m *Monitor) powerOn(ctx context.Context, c Client, config *ClientMonitorConfig) error {
+	pc, ok := c.(PowerController)
+	if !ok {
+		return fmt.Errorf("client has no power service to power it on")
+	}
+	g, ok := m.circuits[config.CircuitGroup]
+	if !ok {
+		reqCtx, cancel := requestContext(ctx, config)
+		defer cancel()
+		return pc.PowerOn(reqCtx)
+	}
+	if err := g.checkPowerOn(c); err != nil {
+		return err
+	}
+	m.EventService.E <- NewLogEvent(c, fmt.Sprintf("waiting for circuit group %s to allow power on...", g.Name))
+	if err := g.acquirePowerOn(ctx); err != nil {
+		return err
+	}
+	reqCtx, cancel := requestContext(ctx, config)
+	defer cancel()
+	if err := g.runPowerOn(reqCtx, pc.PowerOn); err != nil {
+		return err
+	}
+	g.setShutdown(c, false)
+	return nil
+}
+
+// checkCircuitBudget records the power of the client within its circuit group, alerting and shedding the
+// lowest priority client if the group has been over budget for too long
+func (m *Monitor) checkCircuitBudget(ctx context.Context, c Client, config *ClientMonitorConfig, stats *Statistics) {
+	g, ok := m.circuits[config.CircuitGroup]
+	if !ok || stats.PowerState == nil {
+		return
+	}
+	load, over, alert := g.recordPower(c, config.Priority, stats.PowerState.Power)
+	if alert {
+		m.notify(c, "Circuit Over Budget",
+			fmt.Sprintf("Circuit group %s is drawing %0.2fW which is over its budget of %0.2fW", g.Name, load, g.Budget))
+	}
+	if !over || !g.ShedLoad {
+		return
+	}
+	victim := g.shedCandidate()
+	if victim == nil || !victim.PowerCycleEnabled() {
+		return
+	}
+	pc, ok := victim.(PowerController)
+	if !ok {
+		return
+	}
+	m.EventService.E <- NewLogEvent(victim, fmt.Sprintf("circuit group %s over budget, powering off...", g.Name))
+	reqCtx, cancel := requestContext(ctx, config)
+	defer cancel()
+	if err := pc.PowerOff(reqCtx); err != nil {
+		m.EventService.E <- NewErrorEvent(victim, fmt.Errorf("failed to power off: %s", err))
+		return
+	}
+	g.markShed(victim)
+	m.notify(victim, "Powered Off",
+		fmt.Sprintf("Client was powered off as circuit group %s was drawing %0.2fW over its budget of %0.2fW", g.Name, load, g.Budget))
+}
+
+// shedClient returns true if the client has been powered off to keep its circuit group within budget, the
+// client is powered back on once the group has room for it again
+func (m *Monitor) shedClient(ctx context.Context, c Client, config *ClientMonitorConfig) bool {
+	g, ok := m.circuits[config.CircuitGroup]
+	if !ok || !g.shed(c) {
+		return false
+	}
+	if !g.restore(c) {
+		glog.V(1).Infof("[%s] powered off to keep circuit group %s within budget", c.IP(), g.Name)
+		return true
+	}
+	m.EventService.E <- NewLogEvent(c, fmt.Sprintf("circuit group %s within budget, powering on...", g.Name))
+	if err := g.acquirePowerOn(ctx); err != nil {
+		return true
+	}
+	reqCtx, cancel := requestContext(ctx, config)
+	err := g.runPowerOn(reqCtx, c.(PowerController).PowerOn)
+	cancel()
+	if err != nil {
+		m.EventService.E <- NewErrorEvent(c, fmt.Errorf("failed to power on: %s", err))
+		g.markShed(c)
+		return true
+	}
+	m.notify(c, "Powered On", fmt.Sprintf("Client was powered back on as circuit group %s is within its budget", g.Name))
+	return true
+}
@@ -0,0 +1,41 @@
+package miningmonitor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// NetworkGuard is checked before taking an action on a client. It suppresses actions when a farm wide network
+// outage is more likely than a failure of the client, e.g. the switch or the monitor's own network is down.
+type NetworkGuard struct {
+	// Sentinels are host:port addresses, such as the gateway, that must accept a TCP connection before acting
+	Sentinels []string
+	// Timeout connecting to each sentinel
+	Timeout time.Duration
+	// Quorum is the fraction of clients failing to respond at which actions are suppressed, 0 to disable
+	Quorum float64
+}
+
+// NewNetworkGuard returns a guard requiring all sentinels to be reachable within timeout and fewer than the
+// quorum fraction of clients to be unreachable before actions are taken
+func NewNetworkGuard(sentinels []string, timeout time.Duration, quorum float64) *NetworkGuard {
+	return &NetworkGuard{Sentinels: sentinels, Timeout: timeout, Quorum: quorum}
+}
+
+// check returns an error if actions should be suppressed given the number of unreachable clients
+func (g *NetworkGuard) check(ctx context.Context, unreachable, total int) error {
+	if g.Quorum > 0 && total > 1 && float64(unreachable)/float64(total) >= g.Quorum {
+		return fmt.Errorf("%d of %d clients are unreachable, likely a network outage", unreachable, total)
+	}
+	dialer := net.Dialer{Timeout: g.Timeout}
+	for _, sentinel := range g.Sentinels {
+		conn, err := dialer.DialContext(ctx, "tcp", sentinel)
+		if err != nil {
+			return fmt.Errorf("sentinel %s is unreachable, likely a network outage: %s", sentinel, err)
+		}
+		conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package miningmonitor
+
+import (
+	"fmt"
+	"time"
+)
+
+// PreventiveRestart restarts the miner or reboots a healthy client once it has been running for too long, for rigs
+// which degrade slowly after days of uptime.
+type PreventiveRestart struct {
+	// Action taken, either RestartAction or RebootAction
+	Action Action
+	// MaxRunningTime of the miner before the action is taken, the running time is reported in minutes
+	MaxRunningTime time.Duration
+	// Start and End of the time of day the action may be taken in, both offsets from midnight. A window with End
+	// before Start wraps around midnight, a window with Start equal to End allows any time of day.
+	Start time.Duration
+	End   time.Duration
+}
+
+// NewPreventiveRestart returns a preventive restart taking the action once the miner has been running for longer
+// than maxRunningTime, only between start and end offsets from midnight unless they are equal.
+func NewPreventiveRestart(action Action, maxRunningTime, start, end time.Duration) (*PreventiveRestart, error) {
+	if action != RestartAction && action != RebootAction {
+		return nil, fmt.Errorf("invalid preventive restart action %s, it must be %s or %s", action, RestartAction, RebootAction)
+	}
+	if maxRunningTime <= 0 {
+		return nil, fmt.Errorf("invalid preventive restart running time %v, it must be greater than 0", maxRunningTime)
+	}
+	return &PreventiveRestart{Action: action, MaxRunningTime: maxRunningTime, Start: start, End: end}, nil
+}
+
+// String human readable format of a preventive restart
+func (p PreventiveRestart) String() string {
+	if p.Start == p.End {
+		return fmt.Sprintf("%s after running %v", p.Action, p.MaxRunningTime)
+	}
+	return fmt.Sprintf("%s after running %v between %v and %v", p.Action, p.MaxRunningTime, p.Start, p.End)
+}
+
+// due returns true if the client has been running for longer than MaxRunningTime within the time of day window
+func (p *PreventiveRestart) due(now time.Time, runningTime int) bool {
+	if time.Duration(runningTime)*time.Minute < p.MaxRunningTime {
+		return false
+	}
+	return p.Start == p.End || timeOfDayIn(now, p.Start, p.End)
+}
+
+// SetPreventiveRestartGap is the minimum time between preventive restarts of any two clients, so that not all
+// clients restart together
+func (m *Monitor) SetPreventiveRestartGap(gap time.Duration) {
+	m.preventiveMu.Lock()
+	defer m.preventiveMu.Unlock()
+	m.preventiveGap = gap
+}
+
+// preventiveRestartDue returns true if a preventive restart of the client is due and no other client was restarted
+// within the gap, the restart is recorded when true is returned.
+func (m *Monitor) preventiveRestartDue(cm *clientMonitoring, runningTime int) bool {
+	p := cm.Config.PreventiveRestart
+	now := time.Now()
+	if p == nil || !p.due(now, runningTime) {
+		return false
+	}
+	m.preventiveMu.Lock()
+	defer m.preventiveMu.Unlock()
+	if now.Sub(m.lastPreventive) < m.preventiveGap {
+		return false
+	}
+	m.lastPreventive = now
+	return true
+}
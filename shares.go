@@ -0,0 +1,70 @@
+package miningmonitor
+
+import "time"
+
+// minRatioShares is the number of shares found within a window before share ratios are checked, to avoid a
+// single rejected share exceeding a ratio threshold
+const minRatioShares = 10
+
+// shareCounts are the accepted, rejected and invalid shares of a rig or a single GPU
+type shareCounts struct {
+	accepted int
+	rejected int
+	invalid  int
+}
+
+// total shares found
+func (s shareCounts) total() int {
+	return s.accepted + s.rejected + s.invalid
+}
+
+// sub returns the shares found since o
+func (s shareCounts) sub(o shareCounts) shareCounts {
+	return shareCounts{accepted: s.accepted - o.accepted, rejected: s.rejected - o.rejected, invalid: s.invalid - o.invalid}
+}
+
+// shareSample is the share counts of a client at a point in time
+type shareSample struct {
+	at   time.Time
+	rig  shareCounts
+	gpus []shareCounts
+}
+
+// newShareSample returns the main share counts of the statistics
+func newShareSample(at time.Time, stats *Statistics) shareSample {
+	s := shareSample{
+		at:  at,
+		rig: shareCounts{accepted: stats.MainShares, rejected: stats.MainRejectedShares, invalid: stats.MainInvalidShares},
+	}
+	for i := range stats.MainGpuShares {
+		if i >= len(stats.MainGpuRejectedShares) || i >= len(stats.MainGpuInvalidShares) {
+			break
+		}
+		s.gpus = append(s.gpus, shareCounts{
+			accepted: stats.MainGpuShares[i],
+			rejected: stats.MainGpuRejectedShares[i],
+			invalid:  stats.MainGpuInvalidShares[i],
+		})
+	}
+	return s
+}
+
+// shareWindow keeps the share samples within a window so that ratios are calculated on the shares found
+// recently rather than lifetime totals
+type shareWindow struct {
+	window  time.Duration
+	samples []shareSample
+}
+
+// add a sample and return the oldest sample within the window
+func (w *shareWindow) add(s shareSample) shareSample {
+	// Counters go backwards when the miner restarts, start a new window
+	if n := len(w.samples); n > 0 && s.rig.total() < w.samples[n-1].rig.total() {
+		w.samples = nil
+	}
+	w.samples = append(w.samples, s)
+	for len(w.samples) > 1 && s.at.Sub(w.samples[0].at) > w.window {
+		w.samples = w.samples[1:]
+	}
+	return w.samples[0]
+}
@@ -2,6 +2,8 @@ package miningmonitor
 
 import (
 	"fmt"
+	"sort"
+	"time"
 
 	"strconv"
 
@@ -64,18 +66,40 @@ func IntComparatorFromString(s string) IntComparison {
 	}
 }
 
+// GPUThresholdError is returned by thresholds that check each GPU of a client individually
+type GPUThresholdError struct {
+	GPU int
+	Msg string
+	// Algorithm is the algorithm that failed on the GPU
+	Algorithm Algorithm
+}
+
+// Error returns the threshold message
+func (e *GPUThresholdError) Error() string {
+	return e.Msg
+}
+
+// gpuErrorf returns a GPUThresholdError for the given GPU index
+func gpuErrorf(gpu int, format string, a ...interface{}) error {
+	return &GPUThresholdError{GPU: gpu, Msg: fmt.Sprintf(format, a...)}
+}
+
 // Threshold used to take action on a client if exceeded
 type Threshold struct {
 	Check       ThresholdFunc
 	Threshold   string
 	CauseReboot bool
-	SendEmail   bool
-	Name        string
+	// CauseShutdown powers the client off until it has cooled down rather than rebooting it, even during a silence
+	// or maintenance window
+	CauseShutdown bool
+	SendEmail     bool
+	Name          string
 }
 
 // String human readable format ofa threshold
 func (t Threshold) String() string {
-	return fmt.Sprintf("%s: %s - [Cause Reboot? %t, Send Email? %t]", t.Name, t.Threshold, t.CauseReboot, t.SendEmail)
+	return fmt.Sprintf("%s: %s - [Cause Reboot? %t, Cause Shutdown? %t, Send Email? %t]",
+		t.Name, t.Threshold, t.CauseReboot, t.CauseShutdown, t.SendEmail)
 }
 
 // NewHashRateThreshold returns a Threshold that will check if a client has exceeded the given hash rate.
@@ -92,7 +116,7 @@ func NewHashRateThreshold(threshold string, causeReboot, sendEmail bool) (*Thres
 			for i, hash := range stats.MainGpuHashRate {
 				glog.V(2).Infof("GPU %d hashrate %0.2f", i, hash)
 				if comp(int(hash), number) {
-					errors = append(errors, fmt.Errorf("GPU %d threshold exceeded %d%s", i, int(hash), threshold))
+					errors = append(errors, gpuErrorf(i, "GPU %d threshold exceeded %d%s", i, int(hash), threshold))
 				}
 			}
 			return errors
@@ -114,6 +138,9 @@ func NewPowerThreshold(threshold string, causeReboot, sendEmail bool) (*Threshol
 	}
 	return &Threshold{
 		Check: func(stats *Statistics) []error {
+			if stats.PowerState == nil {
+				return nil
+			}
 			glog.V(2).Infof("rig power %0.2f", stats.PowerState.Power)
 			if comp(stats.PowerState.Power, number) {
 				return []error{fmt.Errorf("power threshold exceeded %0.2f%s", stats.PowerState.Power, threshold)}
@@ -141,7 +168,7 @@ func NewTemperatureThreshold(threshold string, causeReboot, sendEmail bool) (*Th
 			for i, temp := range stats.GpuTemperatures {
 				glog.V(2).Infof("GPU %d temperature %0.2f", i, temp)
 				if comp(temp, number) {
-					errors = append(errors, fmt.Errorf("GPU %d temperature threshold exceeded %0.2f%s", i, temp, threshold))
+					errors = append(errors, gpuErrorf(i, "GPU %d temperature threshold exceeded %0.2f%s", i, temp, threshold))
 				}
 			}
 			return errors
@@ -153,6 +180,19 @@ func NewTemperatureThreshold(threshold string, causeReboot, sendEmail bool) (*Th
 	}, nil
 }
 
+// NewCriticalTemperatureThreshold returns a Threshold that will shut down a client once it has exceeded the
+// given temperature, rebooting an overheating client only brings it back to overheat again.
+// threshold should be of the format ">90".
+func NewCriticalTemperatureThreshold(threshold string, sendEmail bool) (*Threshold, error) {
+	t, err := NewTemperatureThreshold(threshold, false, sendEmail)
+	if err != nil {
+		return nil, err
+	}
+	t.CauseShutdown = true
+	t.Name = "CriticalTemp"
+	return t, nil
+}
+
 // NewFanPercentThreshold returns a Threshold that will check if a client has exceeded the given fan percent.
 // threshold should be of the format "<20" or ">20".
 func NewFanPercentThreshold(threshold string, causeReboot, sendEmail bool) (*Threshold, error) {
@@ -167,7 +207,7 @@ func NewFanPercentThreshold(threshold string, causeReboot, sendEmail bool) (*Thr
 			for i, fp := range stats.GpuFanPercents {
 				glog.V(2).Infof("GPU %d fan percent %0.2f", i, fp)
 				if comp(fp, number) {
-					errors = append(errors, fmt.Errorf("GPU %d fan percent threshold exceeded %0.2f%s", i, fp, threshold))
+					errors = append(errors, gpuErrorf(i, "GPU %d fan percent threshold exceeded %0.2f%s", i, fp, threshold))
 				}
 			}
 			return errors
@@ -178,3 +218,656 @@ func NewFanPercentThreshold(threshold string, causeReboot, sendEmail bool) (*Thr
 		Name:        "FanPercent",
 	}, nil
 }
+
+// NewEfficiencyThreshold returns a Threshold that will check if a client has exceeded the given hash rate per watt.
+// threshold should be of the format "<100" or ">100" in kH/s per watt.
+func NewEfficiencyThreshold(threshold string, causeReboot, sendEmail bool) (*Threshold, error) {
+	comp := FloatComparatorFromString(threshold)
+	number, err := strconv.ParseFloat(threshold[1:], 64)
+	if err != nil {
+		return nil, fmt.Errorf("unknown threshold found %s, a threshold must have a first character of '>|<' followed by a number: %s", threshold, err)
+	}
+	return &Threshold{
+		Check: func(stats *Statistics) []error {
+			if stats.PowerState == nil || stats.PowerState.Power <= 0 {
+				return nil
+			}
+			efficiency := stats.MainHashRate / stats.PowerState.Power
+			glog.V(2).Infof("rig efficiency %0.2f", efficiency)
+			if comp(efficiency, number) {
+				return []error{fmt.Errorf("efficiency threshold exceeded %0.2f%s", efficiency, threshold)}
+			}
+			return nil
+		},
+		Threshold:   threshold,
+		CauseReboot: causeReboot,
+		SendEmail:   sendEmail,
+		Name:        "Efficiency",
+	}, nil
+}
+
+// NewWattsPerGPUThreshold returns a Threshold that will check if a client has exceeded the given watts per hashing GPU.
+// GPUs reporting no hash rate are not counted, so a GPU dropping out while the rig draws full power raises the watts per GPU.
+// The rig is not checked while no GPU is hashing.
+// threshold should be of the format "<150" or ">150".
+func NewWattsPerGPUThreshold(threshold string, causeReboot, sendEmail bool) (*Threshold, error) {
+	comp := FloatComparatorFromString(threshold)
+	number, err := strconv.ParseFloat(threshold[1:], 64)
+	if err != nil {
+		return nil, fmt.Errorf("unknown threshold found %s, a threshold must have a first character of '>|<' followed by a number: %s", threshold, err)
+	}
+	return &Threshold{
+		Check: func(stats *Statistics) []error {
+			if stats.PowerState == nil || stats.PowerState.Power <= 0 {
+				return nil
+			}
+			gpus := 0
+			for _, hash := range stats.MainGpuHashRate {
+				if hash > 0 {
+					gpus++
+				}
+			}
+			// No GPU hashes while the miner starts or generates the DAG, the hash rate threshold catches a rig that never does
+			if gpus == 0 {
+				return nil
+			}
+			watts := stats.PowerState.Power / float64(gpus)
+			glog.V(2).Infof("rig watts per GPU %0.2f", watts)
+			if comp(watts, number) {
+				return []error{fmt.Errorf("watts per GPU threshold exceeded %0.2f%s", watts, threshold)}
+			}
+			return nil
+		},
+		Threshold:   threshold,
+		CauseReboot: causeReboot,
+		SendEmail:   sendEmail,
+		Name:        "WattsPerGPU",
+	}, nil
+}
+
+// minStaleWindow is the shortest window of the stale statistics threshold, the running time is reported in minutes
+// and a shorter window fails on healthy miners
+const minStaleWindow = 2 * time.Minute
+
+// NewStaleStatsThreshold returns a Threshold that will check if a client keeps reporting the same statistics, a sign
+// of a frozen miner that still answers the API. The client is stalled if its running time, its main shares or its
+// per GPU hash rates do not change within window. The running time only advances once a minute, so two observed
+// changes can be a minute plus the stats interval apart, window must be at least minStaleWindow and longer than a
+// minute plus the stats interval. The returned Threshold keeps the previous statistics and must not be shared between
+// clients.
+func NewStaleStatsThreshold(window time.Duration, causeReboot, sendEmail bool) (*Threshold, error) {
+	if window < minStaleWindow {
+		return nil, fmt.Errorf("invalid stale statistics window %v, it must be at least %v", window, minStaleWindow)
+	}
+	var prev *Statistics
+	var runningTimeChanged, sharesChanged, hashRatesChanged time.Time
+	return &Threshold{
+		Check: func(stats *Statistics) []error {
+			now := time.Now()
+			if prev == nil {
+				runningTimeChanged, sharesChanged, hashRatesChanged = now, now, now
+			} else {
+				if stats.RunningTime != prev.RunningTime {
+					runningTimeChanged = now
+				}
+				if stats.MainShares != prev.MainShares {
+					sharesChanged = now
+				}
+				if !floatsEqual(stats.MainGpuHashRate, prev.MainGpuHashRate) {
+					hashRatesChanged = now
+				}
+			}
+			prev = stats
+
+			var errors []error
+			if since := now.Sub(runningTimeChanged); since > window {
+				errors = append(errors, fmt.Errorf("running time %d has not advanced for %v", stats.RunningTime, since))
+			}
+			if since := now.Sub(sharesChanged); since > window {
+				errors = append(errors, fmt.Errorf("shares %d have not increased for %v", stats.MainShares, since))
+			}
+			if since := now.Sub(hashRatesChanged); since > window {
+				errors = append(errors, fmt.Errorf("GPU hash rates %v have not changed for %v", stats.MainGpuHashRate, since))
+			}
+			return errors
+		},
+		Threshold:   window.String(),
+		CauseReboot: causeReboot,
+		SendEmail:   sendEmail,
+		Name:        "Stale",
+	}, nil
+}
+
+// NewRejectedShareRatioThreshold returns a Threshold that will check if the percent of rejected shares found within
+// window has exceeded the given percent, for the whole rig or each GPU if perGPU is set.
+// threshold should be of the format ">5" or "<5". The returned Threshold keeps the previous share counts and must
+// not be shared between clients.
+func NewRejectedShareRatioThreshold(threshold string, window time.Duration, perGPU, causeReboot, sendEmail bool) (*Threshold, error) {
+	return newShareRatioThreshold("RejectedShareRatio", "rejected", threshold, window, perGPU, causeReboot, sendEmail,
+		func(s shareCounts) int { return s.rejected })
+}
+
+// NewInvalidShareRatioThreshold returns a Threshold that will check if the percent of invalid shares found within
+// window has exceeded the given percent, for the whole rig or each GPU if perGPU is set.
+// threshold should be of the format ">5" or "<5". The returned Threshold keeps the previous share counts and must
+// not be shared between clients.
+func NewInvalidShareRatioThreshold(threshold string, window time.Duration, perGPU, causeReboot, sendEmail bool) (*Threshold, error) {
+	return newShareRatioThreshold("InvalidShareRatio", "invalid", threshold, window, perGPU, causeReboot, sendEmail,
+		func(s shareCounts) int { return s.invalid })
+}
+
+func newShareRatioThreshold(name, kind, threshold string, window time.Duration, perGPU, causeReboot, sendEmail bool,
+	bad func(shareCounts) int) (*Threshold, error) {
+	comp := FloatComparatorFromString(threshold)
+	number, err := strconv.ParseFloat(threshold[1:], 64)
+	if err != nil {
+		return nil, fmt.Errorf("unknown threshold found %s, a threshold must have a first character of '>|<' followed by a number: %s", threshold, err)
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("invalid share ratio window %v, it must be greater than 0", window)
+	}
+	shares := &shareWindow{window: window}
+	return &Threshold{
+		Check: func(stats *Statistics) []error {
+			sample := newShareSample(time.Now(), stats)
+			first := shares.add(sample)
+			if !perGPU {
+				found := sample.rig.sub(first.rig)
+				if found.total() < minRatioShares {
+					return nil
+				}
+				ratio := float64(bad(found)) / float64(found.total()) * 100
+				glog.V(2).Infof("rig %s share percent %0.2f", kind, ratio)
+				if comp(ratio, number) {
+					return []error{fmt.Errorf("%s share percent threshold exceeded %0.2f%s", kind, ratio, threshold)}
+				}
+				return nil
+			}
+			var errors []error
+			for i, gpu := range sample.gpus {
+				if i >= len(first.gpus) {
+					break
+				}
+				found := gpu.sub(first.gpus[i])
+				if found.total() < minRatioShares {
+					continue
+				}
+				ratio := float64(bad(found)) / float64(found.total()) * 100
+				glog.V(2).Infof("GPU %d %s share percent %0.2f", i, kind, ratio)
+				if comp(ratio, number) {
+					errors = append(errors, gpuErrorf(i, "GPU %d %s share percent threshold exceeded %0.2f%s", i, kind, ratio, threshold))
+				}
+			}
+			return errors
+		},
+		Threshold:   fmt.Sprintf("%s over %v", threshold, window),
+		CauseReboot: causeReboot,
+		SendEmail:   sendEmail,
+		Name:        name,
+	}, nil
+}
+
+// NewPoolFailoverThreshold returns a Threshold that will check if a client is mining on a pool other than the
+// primary pool, i.e. it has failed over to a backup pool.
+func NewPoolFailoverThreshold(primary string, causeReboot, sendEmail bool) (*Threshold, error) {
+	if primary == "" {
+		return nil, fmt.Errorf("a primary pool is required")
+	}
+	return &Threshold{
+		Check: func(stats *Statistics) []error {
+			glog.V(2).Infof("rig mining pool %s", stats.MainMiningPool)
+			if stats.MainMiningPool != primary {
+				return []error{fmt.Errorf("failed over to pool %s from primary pool %s", stats.MainMiningPool, primary)}
+			}
+			return nil
+		},
+		Threshold:   primary,
+		CauseReboot: causeReboot,
+		SendEmail:   sendEmail,
+		Name:        "PoolFailover",
+	}, nil
+}
+
+// NewPoolSwitchThreshold returns a Threshold that will check if the number of pool switches within window has
+// exceeded the given number.
+// threshold should be of the format ">3" or "<3". The returned Threshold keeps the previous pool switch counts and
+// must not be shared between clients.
+func NewPoolSwitchThreshold(threshold string, window time.Duration, causeReboot, sendEmail bool) (*Threshold, error) {
+	comp := IntComparatorFromString(threshold)
+	number, err := strconv.Atoi(threshold[1:])
+	if err != nil {
+		return nil, fmt.Errorf("unknown threshold found %s, a threshold must have a first character of '>|<' followed by a number: %s", threshold, err)
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("invalid pool switch window %v, it must be greater than 0", window)
+	}
+	type poolSwitches struct {
+		at       time.Time
+		switches int
+	}
+	var samples []poolSwitches
+	return &Threshold{
+		Check: func(stats *Statistics) []error {
+			now := time.Now()
+			// Counters go backwards when the miner restarts, start a new window
+			if n := len(samples); n > 0 && stats.MainPoolSwitches < samples[n-1].switches {
+				samples = nil
+			}
+			samples = append(samples, poolSwitches{at: now, switches: stats.MainPoolSwitches})
+			for len(samples) > 1 && now.Sub(samples[0].at) > window {
+				samples = samples[1:]
+			}
+			switches := stats.MainPoolSwitches - samples[0].switches
+			glog.V(2).Infof("rig pool switches %d within %v", switches, window)
+			if comp(switches, number) {
+				return []error{fmt.Errorf("pool switch threshold exceeded %d%s within %v", switches, threshold, window)}
+			}
+			return nil
+		},
+		Threshold:   fmt.Sprintf("%s over %v", threshold, window),
+		CauseReboot: causeReboot,
+		SendEmail:   sendEmail,
+		Name:        "PoolSwitches",
+	}, nil
+}
+
+// NewPoolAllowListThreshold returns a Threshold that will check if a client is mining on a pool that is not in the
+// allowed list, which may be a sign of a hijacked configuration.
+func NewPoolAllowListThreshold(allowed []string, causeReboot, sendEmail bool) (*Threshold, error) {
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("at least one allowed pool is required")
+	}
+	pools := map[string]bool{}
+	for _, pool := range allowed {
+		pools[pool] = true
+	}
+	return &Threshold{
+		Check: func(stats *Statistics) []error {
+			if !pools[stats.MainMiningPool] {
+				return []error{fmt.Errorf("mining on pool %s which is not allowed", stats.MainMiningPool)}
+			}
+			return nil
+		},
+		Threshold:   fmt.Sprintf("%v", allowed),
+		CauseReboot: causeReboot,
+		SendEmail:   sendEmail,
+		Name:        "PoolAllowList",
+	}, nil
+}
+
+// NewGPUCountThreshold returns a Threshold that will check if a client reports fewer GPUs than expected, e.g. a GPU
+// fell off the PCIe bus. If expected is 0 the count is learned from the first sample where every GPU is hashing.
+// The returned Threshold keeps the learned count and must not be shared between clients.
+func NewGPUCountThreshold(expected int, causeReboot, sendEmail bool) (*Threshold, error) {
+	if expected < 0 {
+		return nil, fmt.Errorf("invalid expected GPU count %d", expected)
+	}
+	threshold := "learned"
+	if expected > 0 {
+		threshold = strconv.Itoa(expected)
+	}
+	return &Threshold{
+		Check: func(stats *Statistics) []error {
+			count := len(stats.MainGpuHashRate)
+			if len(stats.GpuTemperatures) > count {
+				count = len(stats.GpuTemperatures)
+			}
+			if expected == 0 {
+				for _, hash := range stats.MainGpuHashRate {
+					if hash <= 0 {
+						return nil
+					}
+				}
+				if count == 0 {
+					return nil
+				}
+				expected = count
+				glog.V(1).Infof("learned expected GPU count %d", expected)
+			}
+			glog.V(2).Infof("rig GPU count %d", count)
+			if count < expected {
+				return []error{fmt.Errorf("GPU count threshold exceeded, %d GPUs reported, expected %d", count, expected)}
+			}
+			return nil
+		},
+		Threshold:   threshold,
+		CauseReboot: causeReboot,
+		SendEmail:   sendEmail,
+		Name:        "GPUCount",
+	}, nil
+}
+
+// fanSample is the temperature and fan percent of a GPU at a point in time
+type fanSample struct {
+	at   time.Time
+	temp float64
+	fan  float64
+}
+
+// NewFanHealthThreshold returns a Threshold that will check if the fan of a GPU is failing. A fan is failing if its
+// temperature rose by at least tempRise degrees within window while its fan percent stayed the same, e.g. stuck at
+// 0% or at 100% of a dead fan, or went down. The returned Threshold keeps the samples within window and must not be
+// shared between clients.
+func NewFanHealthThreshold(tempRise float64, window time.Duration, causeReboot, sendEmail bool) (*Threshold, error) {
+	if tempRise <= 0 {
+		return nil, fmt.Errorf("invalid fan health temperature rise %0.2f, it must be greater than 0", tempRise)
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("invalid fan health window %v, it must be greater than 0", window)
+	}
+	var samples [][]fanSample
+	return &Threshold{
+		Check: func(stats *Statistics) []error {
+			now := time.Now()
+			count := len(stats.GpuTemperatures)
+			if len(stats.GpuFanPercents) < count {
+				count = len(stats.GpuFanPercents)
+			}
+			// Start over if GPUs were added or removed, the indexes may no longer match
+			if len(samples) != count {
+				samples = make([][]fanSample, count)
+			}
+			var errors []error
+			for i := 0; i < count; i++ {
+				gpu := append(samples[i], fanSample{at: now, temp: stats.GpuTemperatures[i], fan: stats.GpuFanPercents[i]})
+				// Keep the last sample taken before the window started so the samples cover the whole window
+				for len(gpu) > 1 && !gpu[1].at.After(now.Add(-window)) {
+					gpu = gpu[1:]
+				}
+				samples[i] = gpu
+				first, last := gpu[0], gpu[len(gpu)-1]
+				if now.Sub(first.at) < window {
+					continue
+				}
+				rise := last.temp - first.temp
+				glog.V(2).Infof("GPU %d temperature rose %0.2f with fan %0.2f%% to %0.2f%% within %v", i, rise, first.fan, last.fan, window)
+				if rise < tempRise {
+					continue
+				}
+				stuck := true
+				for _, s := range gpu {
+					if s.fan != first.fan {
+						stuck = false
+						break
+					}
+				}
+				if stuck {
+					errors = append(errors, gpuErrorf(i, "GPU %d fan stuck at %0.0f%% while temperature rose from %0.2f to %0.2f within %v",
+						i, last.fan, first.temp, last.temp, window))
+				} else if last.fan <= first.fan {
+					errors = append(errors, gpuErrorf(i, "GPU %d fan went from %0.0f%% to %0.0f%% while temperature rose from %0.2f to %0.2f within %v",
+						i, first.fan, last.fan, first.temp, last.temp, window))
+				}
+			}
+			return errors
+		},
+		Threshold:   fmt.Sprintf("+%0.2f within %v", tempRise, window),
+		CauseReboot: causeReboot,
+		SendEmail:   sendEmail,
+		Name:        "FanHealth",
+	}, nil
+}
+
+// minBaselineSamples is the number of healthy samples within a window before a baseline is compared against, to
+// avoid a handful of samples taken while the miner warms up becoming the baseline
+const minBaselineSamples = 10
+
+// NewBaselineHashRateThreshold returns a Threshold that will check if the hash rate of a client dropped more than
+// percent below its baseline, the median hash rate of the healthy samples within window e.g. the last 24h. The
+// baseline follows the rig after an overclock change without hand tuning an absolute hash rate, samples exceeding
+// the threshold are not healthy and never lower the baseline. GPUs disabled by the monitor lower the hash rate, so
+// the threshold should not be combined with ClientMonitorConfig.DisableFailingGPUs. The returned Threshold keeps the
+// samples within window and must not be shared between clients.
+func NewBaselineHashRateThreshold(percent float64, window time.Duration, causeReboot, sendEmail bool) (*Threshold, error) {
+	if percent <= 0 || percent >= 100 {
+		return nil, fmt.Errorf("invalid baseline hash rate drop %0.2f%%, it must be between 0 and 100", percent)
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("invalid baseline window %v, it must be greater than 0", window)
+	}
+	type hashSample struct {
+		at   time.Time
+		hash float64
+	}
+	var samples []hashSample
+	return &Threshold{
+		Check: func(stats *Statistics) []error {
+			now := time.Now()
+			for len(samples) > 0 && !samples[0].at.After(now.Add(-window)) {
+				samples = samples[1:]
+			}
+			hash := stats.MainHashRate
+			// The miner reports no hash rate while starting, it is neither healthy nor a drop
+			if hash <= 0 {
+				return nil
+			}
+			if len(samples) < minBaselineSamples {
+				samples = append(samples, hashSample{at: now, hash: hash})
+				return nil
+			}
+			hashes := make([]float64, len(samples))
+			for i, s := range samples {
+				hashes[i] = s.hash
+			}
+			sort.Float64s(hashes)
+			baseline := hashes[len(hashes)/2]
+			if len(hashes)%2 == 0 {
+				baseline = (hashes[len(hashes)/2-1] + baseline) / 2
+			}
+			drop := (baseline - hash) / baseline * 100
+			glog.V(2).Infof("rig hash rate %0.2f, baseline %0.2f, drop %0.2f%%", hash, baseline, drop)
+			if drop > percent {
+				return []error{fmt.Errorf("baseline hash rate threshold exceeded %0.2f is %0.2f%% below baseline %0.2f>%0.2f%%",
+					hash, drop, baseline, percent)}
+			}
+			samples = append(samples, hashSample{at: now, hash: hash})
+			return nil
+		},
+		Threshold:   fmt.Sprintf(">%0.2f%% below median of %v", percent, window),
+		CauseReboot: causeReboot,
+		SendEmail:   sendEmail,
+		Name:        "BaselineHashRate",
+	}, nil
+}
+
+// GPULimits are thresholds for each GPU of a rig, each of the format "<20000" or ">20000". The limit of a GPU is
+// looked up by its index, then by its label so that GPUs of the same model can share a limit, falling back to
+// Default. An empty limit does not check the GPU.
+type GPULimits struct {
+	Default string
+	// PerGPU limits by GPU index
+	PerGPU map[int]string
+	// PerLabel limits by GPU label, e.g. "RX 580"
+	PerLabel map[string]string
+	// Labels of the GPUs by index, used to look up PerLabel limits and in error messages
+	Labels map[int]string
+}
+
+// String human readable format of the limits
+func (l GPULimits) String() string {
+	return fmt.Sprintf("{Default: %s, PerGPU: %v, PerLabel: %v}", l.Default, l.PerGPU, l.PerLabel)
+}
+
+// limit returns the threshold of the GPU at the given index
+func (l GPULimits) limit(gpu int) string {
+	if threshold, ok := l.PerGPU[gpu]; ok {
+		return threshold
+	}
+	if threshold, ok := l.PerLabel[l.Labels[gpu]]; ok {
+		return threshold
+	}
+	return l.Default
+}
+
+// label returns the name of the GPU used in error messages
+func (l GPULimits) label(gpu int) string {
+	if label, ok := l.Labels[gpu]; ok {
+		return fmt.Sprintf("GPU %d (%s)", gpu, label)
+	}
+	return fmt.Sprintf("GPU %d", gpu)
+}
+
+// validate every limit is of the format "<20000" or ">20000"
+func (l GPULimits) validate() error {
+	limits := []string{l.Default}
+	for _, threshold := range l.PerGPU {
+		limits = append(limits, threshold)
+	}
+	for _, threshold := range l.PerLabel {
+		limits = append(limits, threshold)
+	}
+	for _, threshold := range limits {
+		if threshold == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(threshold[1:], 64); err != nil || (threshold[0] != '<' && threshold[0] != '>') {
+			return fmt.Errorf("unknown threshold found %s, a threshold must have a first character of '>|<' followed by a number", threshold)
+		}
+	}
+	return nil
+}
+
+// NewPerGPUHashRateThreshold returns a Threshold that will check if each GPU has exceeded its own hash rate limit.
+func NewPerGPUHashRateThreshold(limits GPULimits, causeReboot, sendEmail bool) (*Threshold, error) {
+	return newPerGPUThreshold("HashRate", "hashrate", limits, causeReboot, sendEmail,
+		func(stats *Statistics) []float64 { return stats.MainGpuHashRate })
+}
+
+// NewPerGPUTemperatureThreshold returns a Threshold that will check if each GPU has exceeded its own temperature limit.
+func NewPerGPUTemperatureThreshold(limits GPULimits, causeReboot, sendEmail bool) (*Threshold, error) {
+	return newPerGPUThreshold("Temp", "temperature", limits, causeReboot, sendEmail,
+		func(stats *Statistics) []float64 { return stats.GpuTemperatures })
+}
+
+// NewPerGPUFanPercentThreshold returns a Threshold that will check if each GPU has exceeded its own fan percent limit.
+func NewPerGPUFanPercentThreshold(limits GPULimits, causeReboot, sendEmail bool) (*Threshold, error) {
+	return newPerGPUThreshold("FanPercent", "fan percent", limits, causeReboot, sendEmail,
+		func(stats *Statistics) []float64 { return stats.GpuFanPercents })
+}
+
+func newPerGPUThreshold(name, kind string, limits GPULimits, causeReboot, sendEmail bool,
+	values func(stats *Statistics) []float64) (*Threshold, error) {
+	if err := limits.validate(); err != nil {
+		return nil, err
+	}
+	return &Threshold{
+		Check: func(stats *Statistics) []error {
+			var errors []error
+			for i, value := range values(stats) {
+				threshold := limits.limit(i)
+				if threshold == "" {
+					continue
+				}
+				glog.V(2).Infof("%s %s %0.2f", limits.label(i), kind, value)
+				number, _ := strconv.ParseFloat(threshold[1:], 64)
+				if FloatComparatorFromString(threshold)(value, number) {
+					errors = append(errors, gpuErrorf(i, "%s %s threshold exceeded %0.2f%s", limits.label(i), kind, value, threshold))
+				}
+			}
+			return errors
+		},
+		Threshold:   limits.String(),
+		CauseReboot: causeReboot,
+		SendEmail:   sendEmail,
+		Name:        name,
+	}, nil
+}
+
+// NewHysteresisThreshold returns a Threshold that only fires once trigger has been exceeded for holdFor, and keeps
+// firing until the clear threshold is no longer exceeded. e.g. a trigger of ">80" and a clear of ">75" fires after
+// the temperature stays above 80 and stops once it drops to 75. If clear is nil the trigger is used to clear.
+// The returned Threshold keeps state and must not be shared between clients.
+func NewHysteresisThreshold(trigger, clear *Threshold, holdFor time.Duration) *Threshold {
+	if clear == nil {
+		clear = trigger
+	}
+	var exceededSince time.Time
+	active := false
+	return &Threshold{
+		Check: func(stats *Statistics) []error {
+			if active {
+				if errors := clear.Check(stats); len(errors) > 0 {
+					return errors
+				}
+				active = false
+				exceededSince = time.Time{}
+				return nil
+			}
+			errors := trigger.Check(stats)
+			if len(errors) == 0 {
+				exceededSince = time.Time{}
+				return nil
+			}
+			if exceededSince.IsZero() {
+				exceededSince = time.Now()
+			}
+			if time.Since(exceededSince) < holdFor {
+				glog.V(2).Infof("%s threshold exceeded for %v of %v", trigger.Name, time.Since(exceededSince), holdFor)
+				return nil
+			}
+			active = true
+			return errors
+		},
+		Threshold:     fmt.Sprintf("trigger %s, clear %s, hold for %v", trigger.Threshold, clear.Threshold, holdFor),
+		CauseReboot:   trigger.CauseReboot,
+		CauseShutdown: trigger.CauseShutdown,
+		SendEmail:     trigger.SendEmail,
+		Name:          trigger.Name,
+	}
+}
+
+// Algorithm mined by a client, dual mining clients mine a main and an alt algorithm at the same time
+type Algorithm int
+
+const (
+	// MainAlgorithm is the algorithm of the Main statistics, e.g. Ethereum
+	MainAlgorithm Algorithm = iota
+	// AltAlgorithm is the algorithm of the Alt statistics of a dual mining client, e.g. Decred
+	AltAlgorithm
+)
+
+// String human readable name of the algorithm
+func (a Algorithm) String() string {
+	switch a {
+	case MainAlgorithm:
+		return "Main"
+	case AltAlgorithm:
+		return "Alt"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", int(a))
+	}
+}
+
+// NewAlgorithmThreshold returns the threshold checking the statistics of the given algorithm. Thresholds read the
+// Main statistics, for the alt algorithm they are given a copy of the statistics with the Alt statistics in place
+// of the Main ones. Clients which are not dual mining are not checked for the alt algorithm.
+func NewAlgorithmThreshold(algorithm Algorithm, t *Threshold) *Threshold {
+	if algorithm == MainAlgorithm {
+		return t
+	}
+	return &Threshold{
+		Check: func(stats *Statistics) []error {
+			if stats.AltMiningPool == "" {
+				return nil
+			}
+			var errors []error
+			for _, err := range t.Check(stats.alt()) {
+				if gpuErr, ok := err.(*GPUThresholdError); ok {
+					errors = append(errors, &GPUThresholdError{
+						GPU:       gpuErr.GPU,
+						Msg:       fmt.Sprintf("%s %s", algorithm, gpuErr.Msg),
+						Algorithm: algorithm,
+					})
+				} else {
+					errors = append(errors, fmt.Errorf("%s %s", algorithm, err))
+				}
+			}
+			return errors
+		},
+		Threshold:     t.Threshold,
+		CauseReboot:   t.CauseReboot,
+		CauseShutdown: t.CauseShutdown,
+		SendEmail:     t.SendEmail,
+		Name:          algorithm.String() + t.Name,
+	}
+}
@@ -0,0 +1,64 @@
+package miningmonitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPowerThresholdWithoutPowerState(t *testing.T) {
+	threshold, err := NewPowerThreshold(">1000", true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := parseStats(claymore9Result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errors := threshold.Check(stats); len(errors) > 0 {
+		t.Errorf("Check without a power state = %v, want none", errors)
+	}
+	stats.PowerState = &PowerState{On: true, Power: 1200}
+	if errors := threshold.Check(stats); len(errors) != 1 {
+		t.Errorf("Check over the threshold = %v, want 1 error", errors)
+	}
+}
+
+func TestStaleStatsThresholdWindow(t *testing.T) {
+	for _, window := range []time.Duration{0, 30 * time.Second, time.Minute, 90 * time.Second} {
+		if _, err := NewStaleStatsThreshold(window, true, true); err == nil {
+			t.Errorf("NewStaleStatsThreshold(%v) returned no error", window)
+		}
+	}
+	if _, err := NewStaleStatsThreshold(minStaleWindow, true, true); err != nil {
+		t.Errorf("NewStaleStatsThreshold(%v) returned error: %s", minStaleWindow, err)
+	}
+}
+
+func TestAlgorithmThresholdTagsGPUErrors(t *testing.T) {
+	threshold := NewAlgorithmThreshold(AltAlgorithm, &Threshold{
+		Check: func(stats *Statistics) []error {
+			return []error{gpuErrorf(1, "GPU 1 threshold exceeded")}
+		},
+	})
+	errors := threshold.Check(&Statistics{AltMiningPool: "pool"})
+	if len(errors) != 1 {
+		t.Fatalf("Check = %v, want 1 error", errors)
+	}
+	gpuErr, ok := errors[0].(*GPUThresholdError)
+	if !ok || gpuErr.GPU != 1 || gpuErr.Algorithm != AltAlgorithm {
+		t.Errorf("Check = %#v, want an alt algorithm error for GPU 1", errors[0])
+	}
+}
+
+func TestFilterDisabledGPUs(t *testing.T) {
+	errors := []error{
+		&GPUThresholdError{GPU: 0, Msg: "GPU 0"},
+		&GPUThresholdError{GPU: 1, Msg: "Alt GPU 1", Algorithm: AltAlgorithm},
+		&GPUThresholdError{GPU: 1, Msg: "GPU 1"},
+		&GPUThresholdError{GPU: 2, Msg: "Alt GPU 2", Algorithm: AltAlgorithm},
+	}
+	filtered := filterDisabledGPUs(errors, map[int]Algorithm{0: MainAlgorithm, 1: AltAlgorithm})
+	if len(filtered) != 2 || filtered[0] != errors[2] || filtered[1] != errors[3] {
+		t.Errorf("filterDisabledGPUs = %v, want the main GPU 1 and alt GPU 2 errors", filtered)
+	}
+}
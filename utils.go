@@ -1,5 +1,7 @@
 package miningmonitor
 
+import "context"
+
 func fmtErrors(errors []error) string {
 	msg := ""
 	for _, err := range errors {
@@ -7,3 +9,33 @@ func fmtErrors(errors []error) string {
 	}
 	return msg
 }
+
+func floatsEqual(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// withContext runs f until it returns or the context is done, for calls which cannot be cancelled themselves.
+// f keeps running in the background if the context is done first.
+func withContext(ctx context.Context, f func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}